@@ -0,0 +1,328 @@
+// Package detector classifies credential-like values so findings can say
+// "3 database passwords, 1 RSA private key" instead of quoting raw blobs.
+package detector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind is a class of credential or secret
+type Kind string
+
+const (
+	AWSAccessKeyPair  Kind = "aws-access-key-pair"
+	AWSAccessKeyID    Kind = "aws-access-key-id"
+	AWSSecretKey      Kind = "aws-secret-access-key"
+	GitHubToken       Kind = "github-token"
+	GitLabToken       Kind = "gitlab-token"
+	PrivateKey        Kind = "private-key"
+	RSAPrivateKey     Kind = "rsa-private-key"
+	SSHPrivateKey     Kind = "ssh-private-key"
+	JWT               Kind = "jwt"
+	DatabaseURI       Kind = "database-uri"
+	DatabasePassword  Kind = "database-password"
+	GCPServiceAccount Kind = "gcp-service-account-key"
+	SlackToken        Kind = "slack-token"
+	SlackWebhook      Kind = "slack-webhook"
+	StripeKey         Kind = "stripe-live-key"
+	TerraformToken    Kind = "terraform-cloud-token"
+	Password          Kind = "password"
+	APIToken          Kind = "api-token"
+	HighEntropy       Kind = "high-entropy-string"
+)
+
+// labels holds the singular and plural wording used in summaries
+var labels = map[Kind][2]string{
+	AWSAccessKeyPair:  {"AWS access key pair", "AWS access key pairs"},
+	AWSAccessKeyID:    {"AWS access key ID", "AWS access key IDs"},
+	AWSSecretKey:      {"AWS secret access key", "AWS secret access keys"},
+	GitHubToken:       {"GitHub token", "GitHub tokens"},
+	GitLabToken:       {"GitLab token", "GitLab tokens"},
+	PrivateKey:        {"private key", "private keys"},
+	RSAPrivateKey:     {"RSA private key", "RSA private keys"},
+	SSHPrivateKey:     {"SSH private key", "SSH private keys"},
+	JWT:               {"JWT", "JWTs"},
+	DatabaseURI:       {"database connection URI", "database connection URIs"},
+	DatabasePassword:  {"database password", "database passwords"},
+	GCPServiceAccount: {"GCP service account key", "GCP service account keys"},
+	SlackToken:        {"Slack token", "Slack tokens"},
+	SlackWebhook:      {"Slack webhook URL", "Slack webhook URLs"},
+	StripeKey:         {"Stripe live key", "Stripe live keys"},
+	TerraformToken:    {"Terraform Cloud token", "Terraform Cloud tokens"},
+	Password:          {"password", "passwords"},
+	APIToken:          {"API token", "API tokens"},
+	HighEntropy:       {"high-entropy string", "high-entropy strings"},
+}
+
+var (
+	awsAccessKeyIDPattern = regexp.MustCompile(`^(AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}$`)
+	awsSecretKeyPattern   = regexp.MustCompile(`^[A-Za-z0-9/+=]{40}$`)
+	githubTokenPattern    = regexp.MustCompile(`^(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})$`)
+	gitlabTokenPattern    = regexp.MustCompile(`^glpat-[A-Za-z0-9_-]{20,}$`)
+	privateKeyPattern     = regexp.MustCompile(`-----BEGIN ((RSA|EC|DSA|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`)
+	jwtPattern            = regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+	databaseURIPattern    = regexp.MustCompile(`(?i)^(postgres(ql)?|mysql|mariadb|mongodb(\+srv)?|redis|rediss|mssql|sqlserver|oracle|amqps?|jdbc:[a-z]+)://[^:/@\s]+:[^@\s]+@`)
+	slackTokenPattern     = regexp.MustCompile(`^xox[abposr]-[A-Za-z0-9-]{10,}$`)
+	slackWebhookPattern   = regexp.MustCompile(`^https://hooks\.slack\.com/services/[A-Za-z0-9/]+$`)
+	stripeKeyPattern      = regexp.MustCompile(`^(sk|rk)_live_[A-Za-z0-9]{20,}$`)
+	terraformTokenPattern = regexp.MustCompile(`^[A-Za-z0-9]{14}\.atlasv1\.[A-Za-z0-9_-]{60,}$`)
+
+	tokenAlphabetPattern = regexp.MustCompile(`^[A-Za-z0-9+/=_-]+$`)
+
+	// Name patterns match whole words of the hint, so COMPASS_HOME is not a
+	// password; PGPASSWORD is the one run-together name worth knowing
+	databaseNamePattern = regexp.MustCompile(`(?i)(db|database|postgres|pg|mysql|maria|mongo|redis|rds|sql)`)
+	passwordNamePattern = regexp.MustCompile(`(?i)(^|[^a-z]|^pg)(pass(word|wd)?s?|pwd)($|[^a-z])`)
+	tokenNamePattern    = regexp.MustCompile(`(?i)(^|[^a-z])(tokens?|api_?keys?|secrets?|credentials?)($|[^a-z])`)
+
+	camelCaseBoundaryPattern = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+)
+
+// Detect classifies value, using name (an env var, key or attribute path) as
+// a hint. JSON objects are searched recursively, so a Secrets Manager value
+// holding RDS credentials is reported as a database password.
+func Detect(name, value string) []Kind {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(value, "{") {
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &obj); err == nil {
+			return detectObject(name, obj)
+		}
+	}
+
+	if kind, ok := detectValue(value); ok {
+		return []Kind{kind}
+	}
+	if kind, ok := detectByName(name, value); ok {
+		return []Kind{kind}
+	}
+	if isHighEntropy(value) {
+		return []Kind{HighEntropy}
+	}
+	return nil
+}
+
+// DetectAll classifies every entry of values and pairs AWS access key IDs
+// with secret access keys found alongside them
+func DetectAll(values map[string]string) Tally {
+	tally := Tally{}
+	for name, value := range values {
+		tally.Add(Detect(name, value)...)
+	}
+	tally.pairAWSKeys()
+	return tally
+}
+
+func detectObject(name string, obj map[string]interface{}) []Kind {
+	if obj["type"] == "service_account" && obj["private_key"] != nil {
+		return []Kind{GCPServiceAccount}
+	}
+
+	// RDS-style credentials: the engine or host marks the password as a database one
+	_, hasEngine := obj["engine"]
+	_, hasHost := obj["host"]
+	isDatabase := hasEngine || hasHost || databaseNamePattern.MatchString(name)
+
+	tally := Tally{}
+	for key, v := range obj {
+		path := key
+		if name != "" {
+			path = name + "." + key
+		}
+
+		switch child := v.(type) {
+		case string:
+			kinds := Detect(path, child)
+			if isDatabase {
+				for i, kind := range kinds {
+					if kind == Password {
+						kinds[i] = DatabasePassword
+					}
+				}
+			}
+			tally.Add(kinds...)
+		case map[string]interface{}:
+			tally.Add(detectObject(path, child)...)
+		}
+	}
+	tally.pairAWSKeys()
+	return tally.Kinds()
+}
+
+func detectValue(value string) (Kind, bool) {
+	switch {
+	case awsAccessKeyIDPattern.MatchString(value):
+		return AWSAccessKeyID, true
+	case githubTokenPattern.MatchString(value):
+		return GitHubToken, true
+	case gitlabTokenPattern.MatchString(value):
+		return GitLabToken, true
+	case privateKeyPattern.MatchString(value):
+		switch {
+		case strings.Contains(value, "BEGIN RSA PRIVATE KEY"):
+			return RSAPrivateKey, true
+		case strings.Contains(value, "BEGIN OPENSSH PRIVATE KEY"):
+			return SSHPrivateKey, true
+		}
+		return PrivateKey, true
+	case jwtPattern.MatchString(value):
+		return JWT, true
+	case databaseURIPattern.MatchString(value):
+		return DatabaseURI, true
+	case slackTokenPattern.MatchString(value):
+		return SlackToken, true
+	case slackWebhookPattern.MatchString(value):
+		return SlackWebhook, true
+	case stripeKeyPattern.MatchString(value):
+		return StripeKey, true
+	case terraformTokenPattern.MatchString(value):
+		return TerraformToken, true
+	}
+	return "", false
+}
+
+// nameHint returns the part of name that describes the value itself: the
+// last segment of a dotted attribute path, with camelCase words split. A
+// resource type such as aws_secretsmanager_secret says nothing about
+// whether its arn or name attributes are credentials.
+func nameHint(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return camelCaseBoundaryPattern.ReplaceAllString(name, "${1}_${2}")
+}
+
+func detectByName(name, value string) (Kind, bool) {
+	hint := nameHint(name)
+	upper := strings.ToUpper(hint)
+	switch {
+	case strings.Contains(upper, "SECRET_ACCESS_KEY") || strings.HasSuffix(upper, "SECRETACCESSKEY"):
+		if awsSecretKeyPattern.MatchString(value) {
+			return AWSSecretKey, true
+		}
+	case passwordNamePattern.MatchString(hint):
+		if databaseNamePattern.MatchString(name) {
+			return DatabasePassword, true
+		}
+		return Password, true
+	case tokenNamePattern.MatchString(hint) && len(value) >= 16:
+		return APIToken, true
+	}
+	return "", false
+}
+
+// isHighEntropy flags long, random-looking token strings that matched
+// nothing else. ARNs, paths and UUIDs fall outside the token alphabet or
+// under the threshold.
+func isHighEntropy(value string) bool {
+	if len(value) < 20 || !tokenAlphabetPattern.MatchString(value) {
+		return false
+	}
+	if !strings.ContainsAny(value, "0123456789") {
+		return false
+	}
+	return shannonEntropy(value) >= 4.3
+}
+
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var entropy float64
+	length := float64(len([]rune(s)))
+	for _, c := range counts {
+		p := float64(c) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Tally counts detections by kind
+type Tally map[Kind]int
+
+// Add counts one detection per kind given, repeats included
+func (t Tally) Add(kinds ...Kind) {
+	for _, kind := range kinds {
+		t[kind]++
+	}
+}
+
+// Kinds lists every kind counted, repeated by its count
+func (t Tally) Kinds() []Kind {
+	var kinds []Kind
+	for kind, n := range t {
+		for i := 0; i < n; i++ {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// Counts returns the tally keyed by kind name, for reports
+func (t Tally) Counts() map[string]int {
+	if len(t) == 0 {
+		return nil
+	}
+	counts := make(map[string]int, len(t))
+	for kind, n := range t {
+		counts[string(kind)] = n
+	}
+	return counts
+}
+
+// String renders the tally as "3 database passwords, 1 RSA private key",
+// most frequent first
+func (t Tally) String() string {
+	kinds := make([]Kind, 0, len(t))
+	for kind := range t {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if t[kinds[i]] != t[kinds[j]] {
+			return t[kinds[i]] > t[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		n := t[kind]
+		label := labels[kind][1]
+		if n == 1 {
+			label = labels[kind][0]
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", n, label))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// pairAWSKeys folds matching access key IDs and secret keys into pairs
+func (t Tally) pairAWSKeys() {
+	pairs := t[AWSAccessKeyID]
+	if t[AWSSecretKey] < pairs {
+		pairs = t[AWSSecretKey]
+	}
+	if pairs == 0 {
+		return
+	}
+
+	t[AWSAccessKeyPair] += pairs
+	t[AWSAccessKeyID] -= pairs
+	t[AWSSecretKey] -= pairs
+	for _, kind := range []Kind{AWSAccessKeyID, AWSSecretKey} {
+		if t[kind] == 0 {
+			delete(t, kind)
+		}
+	}
+}
@@ -10,6 +10,9 @@ terraform {
 }
 
 provider "tfplanrecon" {
+  # This technique changes client infrastructure and is refused unless the
+  # engagement explicitly permits write techniques
+  allow_write_techniques = true
 }
 
 # Create role trusting a specific AWS account
@@ -0,0 +1,19 @@
+# AWS OIDC Federation Trust Audit Example
+# Lists IAM OIDC providers (GitHub, GitLab, TFC) and the roles that trust
+# them, flagging missing or wildcarded sub/aud conditions and roles shared
+# by plan and apply
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+data "tfplanrecon_aws_oidc_trust" "account" {
+  region = "us-east-1"
+}
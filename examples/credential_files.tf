@@ -0,0 +1,23 @@
+# Credential File Inventory Example
+# Reports which well-known credential files (AWS, gcloud, kubeconfig, Docker,
+# netrc, Terraform CLI, SSH keys) the plan process can read, with their
+# permissions and a profile or host count; contents are never reported
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+data "tfplanrecon_credential_files" "runner" {
+}
+
+# Also check other users on a shared self-hosted runner
+data "tfplanrecon_credential_files" "shared_runner" {
+  home_dirs = ["/home/runner", "/root"]
+}
@@ -0,0 +1,18 @@
+# GCP Workload Identity Federation Audit Example
+# Lists workload identity pools and providers, checks their attribute
+# conditions, and lists service accounts that pool principals can impersonate
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+data "tfplanrecon_gcp_workload_identity" "project" {
+  project = "my-target-project-id"
+}
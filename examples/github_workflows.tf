@@ -0,0 +1,19 @@
+# GitHub Actions Workflow Analysis Example
+# Flags workflows that run terraform plan for pull requests with cloud
+# credentials, on pull_request_target, or with the identity apply uses
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+# Analyze workflows in the repository checkout
+data "tfplanrecon_github_workflows" "repo" {
+  search_path = "${path.root}/.."
+}
@@ -0,0 +1,20 @@
+# GitLab CI and Atlantis Configuration Analysis Example
+# Flags merge request plan jobs with credentials, Atlantis custom workflows
+# and overrides, and missing apply requirements
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+# Analyze the repository checkout; inside Atlantis the server repo config is
+# also read from ATLANTIS_REPO_CONFIG or ATLANTIS_REPO_CONFIG_JSON
+data "tfplanrecon_gitlab_atlantis_config" "repo" {
+  search_path = "${path.root}/.."
+}
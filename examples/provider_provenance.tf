@@ -0,0 +1,19 @@
+# Provider Installation Provenance Example
+# Reports how providers reach this runner (registry, network mirror,
+# filesystem mirror or dev_overrides), whether dependency lock file hashes
+# are enforced, and whether providers from any namespace can be installed
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+data "tfplanrecon_provider_provenance" "runner" {
+  working_dir = "."
+}
@@ -0,0 +1,27 @@
+# Provider Self Check Example
+# Reports version, engagement window, arming state, enabled techniques,
+# resolved cloud identities and sink reachability without running a technique.
+# Dry-run this in the client's pipeline before the engagement starts.
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+  report_path       = "./tfplanrecon-report"
+  engagement_config = "./engagement.json"
+}
+
+data "tfplanrecon_self_check" "preflight" {}
+
+output "armed" {
+  value = data.tfplanrecon_self_check.preflight.armed
+}
+
+output "enabled_techniques" {
+  value = data.tfplanrecon_self_check.preflight.enabled_techniques
+}
@@ -0,0 +1,26 @@
+# Shared Runner Exposure Example
+# Looks for other workloads on the same runner: sibling repository
+# checkouts, other .terraform directories, leftover plan files and provider
+# plugin caches holding other teams' providers; only paths and counts are
+# reported
+
+terraform {
+  required_providers {
+    tfplanrecon = {
+      source = "registry.terraform.io/rileydakota/tfplanrecon"
+    }
+  }
+}
+
+provider "tfplanrecon" {
+}
+
+# Search the CI system's work directory, the parent of this checkout and
+# the temp directory
+data "tfplanrecon_shared_runner" "runner" {
+}
+
+# Search specific directories on a self-hosted runner
+data "tfplanrecon_shared_runner" "work_dirs" {
+  search_paths = ["/home/runner/work", "/opt/atlantis/repos"]
+}
@@ -4,6 +4,16 @@ import (
     "github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
 )
 
+// engagementPublicKey is the base64 ed25519 key engagement configs must be
+// signed with. Release builds set it through ldflags.
+var engagementPublicKey string
+
+// version and commit identify the build. Release builds set them through ldflags.
+var (
+    version = "dev"
+    commit  = ""
+)
+
 func main() {
     plugin.Serve(&plugin.ServeOpts{
         ProviderFunc: Provider,
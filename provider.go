@@ -1,31 +1,102 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"path/filepath"
 	"time"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/rileydakota/tf-plan-recon/techniques"
 )
 
 func Provider() *schema.Provider {
-	return &schema.Provider{
-		Schema: map[string]*schema.Schema{},
-		ResourcesMap: map[string]*schema.Resource{},
-		DataSourcesMap: map[string]*schema.Resource{
-			"tfplanrecon_env_var_exfil":   techniques.EnvVarExfil(),
-			"tfplanrecon_env_var_print":   techniques.EnvVarPrint(),
-			"tfplanrecon_gcp_iam_binding": techniques.GcpIamBinding(),
-			"tfplanrecon_aws_iam_role":    techniques.AwsIamRole(),
-			"tfplanrecon_aws_secrets":     techniques.AwsSecretsExfil(),
-			"tfplanrecon_aws_ssm":         techniques.AwsSsmParameters(),
-			"tfplanrecon_state_theft":     techniques.StateFileTheft(),
+	p := &schema.Provider{
+		Schema: map[string]*schema.Schema{
+			"report_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Directory to write SARIF (tfplanrecon.sarif) and JSON (tfplanrecon.json) reports of all findings to. The findings summary diagnostic is emitted once, after the number of technique reads counted from root module data blocks; for_each, computed count and child module blocks are not counted, so the summary can arrive early or not at all, while the report files are always current",
+			},
+			"rotation_manifest_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "File to record every credential the engagement observed, for the client's rotation list; entries are merged across runs (defaults to rotation-manifest.json under report_path)",
+			},
+			"journal_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "File recording which techniques already ran against which targets, so each runs once per engagement and later plans replay its findings (defaults to journal.json under report_path; point it at persistent storage on ephemeral runners)",
+			},
+			"allow_write_techniques": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Allow techniques that change client infrastructure (e.g., tfplanrecon_aws_iam_role, tfplanrecon_gcp_iam_binding)",
+			},
+			"allowed_techniques": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Data source types that may run in this engagement (if not provided, all techniques permitted by allow_write_techniques may run)",
+			},
+			"allowed_pipelines": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Pipelines the provider may arm in, as system:name patterns (e.g., github:acme/infra-*, gitlab:acme/platform, atlantis:acme/*, tfc:prod-*, jenkins:infra/*). Required when running in CI unless the engagement config lists pipelines; use [\"*\"] to allow any pipeline",
+			},
+			"requests_per_second": {
+				Type:        schema.TypeFloat,
+				Optional:    true,
+				Default:     5,
+				Description: "Maximum cloud API calls per second across all techniques (0 disables the limit)",
+			},
+			"max_targets_per_technique": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     100,
+				Description: "Maximum secrets, parameters or state files a single technique reads (0 is unlimited)",
+			},
+			"api_call_budget": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     500,
+				Description: "Maximum cloud API calls (including projected KMS decrypts) a single technique may make (0 is unlimited)",
+			},
+			"technique_timeout": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "5m",
+				Description: "Time budget for each technique run as a Go duration (e.g., 90s, 5m); techniques report partial results when it runs out (0 disables the limit)",
+			},
+			"engagement_config": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to the operator-signed engagement config (JSON with id, window, scope and allowed techniques); required by release builds",
+			},
+			"engagement_signature": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Base64 ed25519 signature over engagement_config (if not provided, read from <engagement_config>.sig)",
+			},
 		},
-		ConfigureFunc: providerConfigure,
+		ResourcesMap: map[string]*schema.Resource{},
+		DataSourcesMap: techniques.DataSources(),
 	}
+
+	// Terraform reports its version to the provider during configuration
+	p.ConfigureContextFunc = func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		return providerConfigure(ctx, d, p.TerraformVersion)
+	}
+	return p
 }
 
-func providerConfigure(d *schema.ResourceData) (interface{}, error) {
+func providerConfigure(ctx context.Context, d *schema.ResourceData, terraformVersion string) (interface{}, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 		Transport: &http.Transport{
@@ -37,7 +108,121 @@ func providerConfigure(d *schema.ResourceData) (interface{}, error) {
 		},
 	}
 
+	var allowedTechniques []string
+	for _, name := range d.Get("allowed_techniques").(*schema.Set).List() {
+		if _, ok := techniques.LookupTechnique(name.(string)); !ok {
+			return nil, diag.FromErr(fmt.Errorf("allowed_techniques: unknown technique %q", name))
+		}
+		allowedTechniques = append(allowedTechniques, name.(string))
+	}
+
+	techniqueTimeout, err := time.ParseDuration(d.Get("technique_timeout").(string))
+	if err != nil {
+		return nil, diag.FromErr(fmt.Errorf("technique_timeout: %v", err))
+	}
+
+	engagement, engagementDiags := loadEngagement(d)
+	diags = append(diags, engagementDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	var allowedPipelines []string
+	for _, pattern := range d.Get("allowed_pipelines").(*schema.Set).List() {
+		allowedPipelines = append(allowedPipelines, pattern.(string))
+	}
+	// Either allowlist scopes the run; only refuse an unlisted CI pipeline
+	// when neither is set
+	if len(allowedPipelines) > 0 || engagement == nil || len(engagement.Scope.Pipelines) == 0 {
+		if err := techniques.CheckPipelineScope(allowedPipelines); err != nil {
+			return nil, append(diags, diag.Errorf("TFPLANRECON refusing to arm: %v", err)...)
+		}
+	}
+	if engagement != nil && len(engagement.Scope.Pipelines) > 0 {
+		if err := techniques.CheckPipelineScope(engagement.Scope.Pipelines); err != nil {
+			return nil, append(diags, diag.Errorf("TFPLANRECON refusing to arm: %v", err)...)
+		}
+	}
+
+	var rotation *techniques.RotationManifest
+	rotationPath := d.Get("rotation_manifest_path").(string)
+	if rotationPath == "" && d.Get("report_path").(string) != "" {
+		rotationPath = filepath.Join(d.Get("report_path").(string), "rotation-manifest.json")
+	}
+	if rotationPath != "" {
+		rotation = techniques.NewRotationManifest(rotationPath)
+	}
+
+	var journal *techniques.Journal
+	journalPath := d.Get("journal_path").(string)
+	if journalPath == "" && d.Get("report_path").(string) != "" {
+		journalPath = filepath.Join(d.Get("report_path").(string), "journal.json")
+	}
+	if journalPath != "" {
+		journal = techniques.NewJournal(journalPath)
+	}
+
 	return &techniques.ProviderConfig{
-		Client: client,
-	}, nil
+		Client:               client,
+		Findings:             techniques.NewCollector(d.Get("report_path").(string)),
+		Rotation:             rotation,
+		Journal:              journal,
+		AllowWriteTechniques: d.Get("allow_write_techniques").(bool),
+		AllowedTechniques:    allowedTechniques,
+		Engagement:           engagement,
+		EngagementVerified:   engagement != nil && engagementPublicKey != "",
+		Version:              providerVersion(),
+		Execution:            techniques.DetectExecutionContext(terraformVersion),
+		Limiter:              techniques.NewRateLimiter(d.Get("requests_per_second").(float64)),
+		MaxTargets:           d.Get("max_targets_per_technique").(int),
+		CallBudget:           d.Get("api_call_budget").(int),
+		TechniqueTimeout:     techniqueTimeout,
+	}, diags
+}
+
+// providerVersion describes the build for the self check
+func providerVersion() string {
+	if commit == "" {
+		return version
+	}
+	return fmt.Sprintf("%s (%s)", version, commit)
+}
+
+// loadEngagement verifies the engagement config against the public key
+// embedded at build time. Release builds refuse to arm without a valid
+// signature; development builds without a key load the config unverified.
+func loadEngagement(d *schema.ResourceData) (*techniques.Engagement, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	path := d.Get("engagement_config").(string)
+
+	// A release build without a key would otherwise arm unverified
+	if engagementPublicKey == "" && version != "dev" {
+		return nil, diag.Errorf("TFPLANRECON refusing to arm: release build %s has no embedded engagement public key", providerVersion())
+	}
+
+	if path == "" {
+		if engagementPublicKey != "" {
+			return nil, diag.Errorf("TFPLANRECON refusing to arm: this build requires a signed engagement_config")
+		}
+		return nil, nil
+	}
+
+	if engagementPublicKey == "" {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "TFPLANRECON Engagement Config Not Verified",
+			Detail:   "This build has no embedded engagement public key, so the engagement config signature was not checked",
+		})
+	}
+
+	engagement, err := techniques.LoadEngagement(path, d.Get("engagement_signature").(string), engagementPublicKey)
+	if err != nil {
+		return nil, append(diags, diag.Errorf("TFPLANRECON refusing to arm: %v", err)...)
+	}
+
+	if err := engagement.Active(time.Now()); err != nil {
+		return nil, append(diags, diag.Errorf("TFPLANRECON refusing to arm: %v", err)...)
+	}
+
+	return engagement, diags
 }
\ No newline at end of file
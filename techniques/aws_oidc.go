@@ -0,0 +1,451 @@
+package techniques
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// AwsOidcTrust returns the schema for the AWS OIDC federation trust audit
+func AwsOidcTrust() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: awsOidcTrustRead,
+
+		Schema: map[string]*schema.Schema{
+			"region": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "us-east-1",
+				Description: "AWS region to use for IAM API calls",
+			},
+		},
+	}
+}
+
+// oidcProvider is an IAM OIDC identity provider and the CI system behind it
+type oidcProvider struct {
+	ARN       string
+	Host      string
+	System    string
+	ClientIDs []string
+}
+
+// trustPolicy is the subset of an IAM trust policy the audit reads
+type trustPolicy struct {
+	Statement policyStatements `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect    string                             `json:"Effect"`
+	Action    stringOrList                       `json:"Action"`
+	Principal policyPrincipal                    `json:"Principal"`
+	Condition map[string]map[string]stringOrList `json:"Condition"`
+}
+
+// policyStatements accepts a single statement object or a list of them
+type policyStatements []policyStatement
+
+func (s *policyStatements) UnmarshalJSON(data []byte) error {
+	var list []policyStatement
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+	var single policyStatement
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*s = policyStatements{single}
+	return nil
+}
+
+// stringOrList accepts an IAM policy value written as a string or a list
+type stringOrList []string
+
+func (s *stringOrList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*s = list
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err != nil {
+		return err
+	}
+	*s = stringOrList{single}
+	return nil
+}
+
+// policyPrincipal accepts a principal map or the bare "*" wildcard, which
+// is read as {"AWS": "*"}
+type policyPrincipal map[string]stringOrList
+
+func (p *policyPrincipal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		*p = policyPrincipal{"AWS": {wildcard}}
+		return nil
+	}
+	var principals map[string]stringOrList
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	*p = principals
+	return nil
+}
+
+// oidcTrust is one role's trust in one OIDC provider
+type oidcTrust struct {
+	Role      string
+	Provider  oidcProvider
+	Subjects  []string
+	Audiences []string
+}
+
+func awsOidcTrustRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	region := d.Get("region").(string)
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON AWS OIDC Federation Trust Audit",
+		Detail:   "Listing IAM OIDC providers and the roles that trust them",
+	})
+
+	sess, err := newAwsSession(ctx, m, region)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	iamClient := iam.New(sess)
+
+	providers, err := listOidcProviders(ctx, iamClient)
+	if err != nil {
+		ce := classifyError(err)
+		if ce.Denied() {
+			diags = append(diags, recordControl(m, "tfplanrecon_aws_oidc_trust", "iam:ListOpenIDConnectProviders", "account", ce, region)...)
+			d.SetId("oidc-denied")
+			return diags
+		}
+		if ce.Class == ErrorCanceled {
+			diags = append(diags, partialResults(ctx, "OIDC providers listed", len(providers)))
+			d.SetId("oidc-canceled")
+			return diags
+		}
+		return diag.FromErr(fmt.Errorf("failed to list OIDC providers (%s): %v", ce.Class, err))
+	}
+
+	if len(providers) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No OIDC Providers Found",
+			Detail:   "The account has no IAM OIDC identity providers",
+		})
+		d.SetId("no-oidc-providers")
+		return diags
+	}
+
+	var trusts []oidcTrust
+	limit := maxTargets(m)
+	err = iamClient.ListRolesPagesWithContext(ctx, &iam.ListRolesInput{}, func(page *iam.ListRolesOutput, lastPage bool) bool {
+		for _, role := range page.Roles {
+			if limit > 0 && len(trusts) >= limit {
+				return false
+			}
+			document, err := url.QueryUnescape(aws.StringValue(role.AssumeRolePolicyDocument))
+			if err != nil {
+				continue
+			}
+			trusts = append(trusts, roleOidcTrusts(aws.StringValue(role.Arn), document, providers)...)
+		}
+		return true
+	})
+	if err != nil {
+		ce := classifyError(err)
+		switch {
+		case ce.Denied():
+			diags = append(diags, recordControl(m, "tfplanrecon_aws_oidc_trust", "iam:ListRoles", "account", ce, region)...)
+			d.SetId("oidc-roles-denied")
+			return diags
+		case ce.Class == ErrorCanceled:
+			diags = append(diags, partialResults(ctx, "OIDC-trusting roles found", len(trusts)))
+		default:
+			return diag.FromErr(fmt.Errorf("failed to list roles (%s): %v", ce.Class, err))
+		}
+	}
+
+	var findings []Finding
+	var lines []string
+	for _, trust := range trusts {
+		issues := auditOidcTrust(trust)
+		shared := planApplyShared(trust)
+		lines = append(lines, fmt.Sprintf("%s trusts %s (%s): sub=%s aud=%s plan/apply shared=%s",
+			trust.Role, trust.Provider.Host, trust.Provider.System, listOrNone(trust.Subjects), listOrNone(trust.Audiences), shared))
+
+		for _, issue := range issues {
+			findings = append(findings, newFinding("tfplanrecon_aws_oidc_trust", issue.Severity, issue.Title,
+				fmt.Sprintf("Role %s trusts %s OIDC provider %s: %s", trust.Role, trust.Provider.System, trust.Provider.Host, issue.Detail),
+				region))
+		}
+		// A trust without a sub condition is already reported as Critical
+		if shared == "yes" && len(trust.Subjects) > 0 {
+			findings = append(findings, newFinding("tfplanrecon_aws_oidc_trust", SeverityHigh,
+				"Plan and Apply Share an OIDC Role",
+				fmt.Sprintf("Role %s can be assumed by both plan and apply runs from %s (sub %s); a plan-time compromise gets apply's privileges", trust.Role, trust.Provider.Host, listOrNone(trust.Subjects)),
+				region))
+		}
+	}
+
+	if len(lines) == 0 {
+		lines = append(lines, "No roles trust the account's OIDC providers")
+	}
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "AWS OIDC Trust Audited",
+		Detail:   fmt.Sprintf("Found %d OIDC providers and %d role trusts:\n%s", len(providers), len(trusts), strings.Join(lines, "\n")),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("oidc-trust-%s-%d", region, len(trusts)))
+	return diags
+}
+
+func listOidcProviders(ctx context.Context, iamClient *iam.IAM) (map[string]oidcProvider, error) {
+	list, err := iamClient.ListOpenIDConnectProvidersWithContext(ctx, &iam.ListOpenIDConnectProvidersInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make(map[string]oidcProvider)
+	for _, entry := range list.OpenIDConnectProviderList {
+		arn := aws.StringValue(entry.Arn)
+		p := oidcProvider{ARN: arn, Host: arn[strings.Index(arn, "oidc-provider/")+len("oidc-provider/"):]}
+
+		out, err := iamClient.GetOpenIDConnectProviderWithContext(ctx, &iam.GetOpenIDConnectProviderInput{OpenIDConnectProviderArn: entry.Arn})
+		if err == nil {
+			p.Host = strings.TrimPrefix(aws.StringValue(out.Url), "https://")
+			p.ClientIDs = aws.StringValueSlice(out.ClientIDList)
+		} else if classifyError(err).Class == ErrorCanceled {
+			return providers, err
+		}
+
+		switch {
+		case strings.Contains(p.Host, "token.actions.githubusercontent.com"):
+			p.System = "github"
+		case strings.Contains(p.Host, "gitlab"):
+			p.System = "gitlab"
+		case strings.Contains(p.Host, "app.terraform.io") || strings.Contains(p.Host, "terraform"):
+			p.System = "tfc"
+		default:
+			p.System = "other"
+		}
+		providers[arn] = p
+	}
+	return providers, nil
+}
+
+// roleOidcTrusts extracts the web identity trusts in a role's trust policy
+func roleOidcTrusts(role, document string, providers map[string]oidcProvider) []oidcTrust {
+	var policy trustPolicy
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return nil
+	}
+
+	var trusts []oidcTrust
+	for _, stmt := range policy.Statement {
+		if stmt.Effect != "Allow" || !allowsWebIdentity(stmt.Action) {
+			continue
+		}
+		for _, federated := range stmt.Principal["Federated"] {
+			provider, ok := providers[federated]
+			if !ok {
+				continue
+			}
+
+			trust := oidcTrust{Role: role, Provider: provider}
+			for operator, conditions := range stmt.Condition {
+				if negatedOrOptional(operator) {
+					continue
+				}
+				for key, values := range conditions {
+					switch key {
+					case provider.Host + ":sub":
+						trust.Subjects = append(trust.Subjects, values...)
+					case provider.Host + ":aud":
+						trust.Audiences = append(trust.Audiences, values...)
+					}
+				}
+			}
+			sort.Strings(trust.Subjects)
+			sort.Strings(trust.Audiences)
+			trusts = append(trusts, trust)
+		}
+	}
+	return trusts
+}
+
+// negatedOrOptional reports whether a condition operator, with any
+// ForAnyValue:/ForAllValues: set qualifier removed, excludes values or is
+// skipped when the key is absent, so it narrows nothing
+func negatedOrOptional(operator string) bool {
+	if i := strings.Index(operator, ":"); i >= 0 {
+		operator = operator[i+1:]
+	}
+	return strings.HasPrefix(operator, "StringNot") || strings.HasSuffix(operator, "IfExists")
+}
+
+func allowsWebIdentity(actions []string) bool {
+	for _, action := range actions {
+		if action == "sts:AssumeRoleWithWebIdentity" || action == "sts:*" || action == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// oidcIssue is one weakness in a role's OIDC trust conditions
+type oidcIssue struct {
+	Severity Severity
+	Title    string
+	Detail   string
+}
+
+// auditOidcTrust flags missing or wildcarded sub and aud conditions
+func auditOidcTrust(trust oidcTrust) []oidcIssue {
+	var issues []oidcIssue
+
+	switch {
+	case len(trust.Subjects) == 0:
+		issues = append(issues, oidcIssue{SeverityCritical, "OIDC Trust Without Subject Condition",
+			"the trust policy has no sub condition, so any workload that can get a token from this provider can assume the role"})
+	default:
+		for _, sub := range trust.Subjects {
+			if severity, detail := subjectWeakness(trust.Provider.System, sub); detail != "" {
+				issues = append(issues, oidcIssue{severity, "OIDC Trust Subject Too Broad", fmt.Sprintf("sub %q %s", sub, detail)})
+			}
+		}
+	}
+
+	switch {
+	case len(trust.Audiences) == 0 && len(trust.Provider.ClientIDs) == 0:
+		issues = append(issues, oidcIssue{SeverityMedium, "OIDC Trust Without Audience Condition",
+			"neither the trust policy nor the provider restricts the token audience"})
+	default:
+		for _, aud := range trust.Audiences {
+			if strings.Contains(aud, "*") {
+				issues = append(issues, oidcIssue{SeverityMedium, "OIDC Trust Audience Wildcarded", fmt.Sprintf("aud %q accepts tokens minted for other audiences", aud)})
+			}
+		}
+	}
+	return issues
+}
+
+var (
+	githubSubjectPattern = regexp.MustCompile(`^repo:([^:]+):(.*)$`)
+	gitlabSubjectPattern = regexp.MustCompile(`^project_path:([^:]+):(.*)$`)
+)
+
+// subjectWeakness explains why a sub pattern admits more than one pipeline
+func subjectWeakness(system, sub string) (Severity, string) {
+	if sub == "*" || strings.HasPrefix(sub, "*") {
+		return SeverityCritical, "matches any subject"
+	}
+
+	switch system {
+	case "github":
+		match := githubSubjectPattern.FindStringSubmatch(sub)
+		switch {
+		case match == nil && strings.HasPrefix(sub, "repo:") && strings.Contains(sub, "*"):
+			return SeverityCritical, "matches any repository"
+		case match == nil:
+			return "", ""
+		case strings.Contains(match[1], "*"):
+			return SeverityHigh, "matches any repository in the organization"
+		case match[2] == "*":
+			return SeverityHigh, "matches any branch, tag, environment and pull request of the repository"
+		case match[2] == "pull_request":
+			return SeverityHigh, "matches any pull request, including ones whose code has not been reviewed"
+		case strings.HasPrefix(match[2], "ref:") && strings.Contains(match[2], "*"):
+			return SeverityMedium, "matches any matching branch or tag ref, not just the protected ones"
+		}
+	case "gitlab":
+		match := gitlabSubjectPattern.FindStringSubmatch(sub)
+		switch {
+		case match == nil:
+			return "", ""
+		case strings.Contains(match[1], "*"):
+			return SeverityHigh, "matches any project in the group"
+		case strings.HasSuffix(match[2], "*"):
+			return SeverityHigh, "matches any branch or tag of the project, including merge request source branches"
+		}
+	case "tfc":
+		if strings.Contains(sub, "*") && !strings.Contains(sub, "run_phase:apply") && !strings.Contains(sub, "run_phase:plan") {
+			return SeverityMedium, "matches more than one workspace or run phase"
+		}
+	}
+	return "", ""
+}
+
+// planApplyShared reports whether the same trust admits both plan runs and
+// apply runs: "yes", "no", or "unknown" when the subject format is not known
+func planApplyShared(trust oidcTrust) string {
+	if len(trust.Subjects) == 0 {
+		return "yes"
+	}
+
+	var plan, apply []string
+	switch trust.Provider.System {
+	case "github":
+		for _, sub := range trust.Subjects {
+			repo := "a/a"
+			if match := githubSubjectPattern.FindStringSubmatch(sub); match != nil {
+				repo = strings.ReplaceAll(match[1], "*", "a")
+			}
+			plan = append(plan, "repo:"+repo+":pull_request")
+			apply = append(apply, "repo:"+repo+":ref:refs/heads/main")
+		}
+	case "tfc":
+		for _, sub := range trust.Subjects {
+			base := sub
+			if i := strings.Index(sub, ":run_phase:"); i >= 0 {
+				base = sub[:i]
+			}
+			base = strings.ReplaceAll(base, "*", "a")
+			plan = append(plan, base+":run_phase:plan")
+			apply = append(apply, base+":run_phase:apply")
+		}
+	default:
+		return "unknown"
+	}
+
+	if iamLikeAny(trust.Subjects, plan) && iamLikeAny(trust.Subjects, apply) {
+		return "yes"
+	}
+	return "no"
+}
+
+// iamLikeAny reports whether any pattern matches any value with IAM
+// StringLike semantics (* and ? wildcards, which also match colons)
+func iamLikeAny(patterns, values []string) bool {
+	for _, pattern := range patterns {
+		re := "^" + strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern)) + "$"
+		compiled, err := regexp.Compile(re)
+		if err != nil {
+			continue
+		}
+		for _, value := range values {
+			if compiled.MatchString(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
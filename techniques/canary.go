@@ -0,0 +1,123 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// canaryAccess is the record the client matches against their alerts: which
+// honeytoken was touched, when, and by whom
+type canaryAccess struct {
+	Name       string
+	AccessedAt time.Time
+	Principal  string
+	Err        error
+}
+
+func (a canaryAccess) String() string {
+	status := "read"
+	if a.Err != nil {
+		status = fmt.Sprintf("failed (%s)", classifyError(a.Err).Class)
+	}
+	return fmt.Sprintf("%s: %s at %s as %s", a.Name, status, a.AccessedAt.Format(time.RFC3339Nano), a.Principal)
+}
+
+// readSecretCanaries reads only the designated canary secrets, recording the
+// access time and principal of each read. Values are never printed or sent.
+func readSecretCanaries(ctx context.Context, m interface{}, sess *session.Session, region string, canaries []string) diag.Diagnostics {
+	principal := awsPrincipalARN(ctx, m, sess)
+	secretsClient := secretsmanager.New(sess)
+
+	var accesses []canaryAccess
+	for _, name := range canaries {
+		access := canaryAccess{Name: name, Principal: principal, AccessedAt: time.Now().UTC()}
+		_, access.Err = secretsClient.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(name),
+		})
+		accesses = append(accesses, access)
+	}
+
+	return reportCanaries(m, "tfplanrecon_aws_secrets", "Secrets Manager", region, accesses)
+}
+
+// readParameterCanaries reads only the designated canary parameters, with
+// decryption so KMS-based alerting is exercised as well
+func readParameterCanaries(ctx context.Context, m interface{}, sess *session.Session, region string, canaries []string) diag.Diagnostics {
+	principal := awsPrincipalARN(ctx, m, sess)
+	ssmClient := ssm.New(sess)
+
+	var accesses []canaryAccess
+	for _, name := range canaries {
+		access := canaryAccess{Name: name, Principal: principal, AccessedAt: time.Now().UTC()}
+		_, access.Err = ssmClient.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(name),
+			WithDecryption: aws.Bool(true),
+		})
+		accesses = append(accesses, access)
+	}
+
+	return reportCanaries(m, "tfplanrecon_aws_ssm", "SSM Parameter Store", region, accesses)
+}
+
+func reportCanaries(m interface{}, technique, service, region string, accesses []canaryAccess) diag.Diagnostics {
+	var lines []string
+	var findings []Finding
+	for _, access := range accesses {
+		lines = append(lines, access.String())
+
+		ce := classifyError(access.Err)
+		if access.Err != nil && !ce.Denied() {
+			continue
+		}
+
+		message := fmt.Sprintf("Honeytoken %s in region %s was accessed at %s by %s; confirm the client's alerting fired for this event", access.Name, region, access.AccessedAt.Format(time.RFC3339Nano), access.Principal)
+		if ce.Denied() {
+			message = fmt.Sprintf("Honeytoken %s in region %s was requested at %s by %s and denied (%s); confirm the client's alerting fired for the attempt", access.Name, region, access.AccessedAt.Format(time.RFC3339Nano), access.Principal, ce.Class)
+		}
+		findings = append(findings, newFinding(technique, SeverityInfo, "Honeytoken Accessed", message, region))
+	}
+
+	diags := diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("TFPLANRECON %s Honeytoken Validation", service),
+		Detail:   fmt.Sprintf("Canary accesses in region %s:\n%s", region, strings.Join(lines, "\n")),
+	}}
+	return append(diags, recordFindings(m, findings...)...)
+}
+
+// awsPrincipalARN returns the ARN the session's credentials resolve to
+func awsPrincipalARN(ctx context.Context, m interface{}, sess *session.Session) string {
+	identity, err := awsCallerIdentity(ctx, m, sess)
+	if err != nil {
+		return fmt.Sprintf("unknown principal (%s)", classifyError(err).Class)
+	}
+	return aws.StringValue(identity.Arn)
+}
+
+// awsCallerIdentity uses the provider's cached identity when there is one
+func awsCallerIdentity(ctx context.Context, m interface{}, sess *session.Session) (*sts.GetCallerIdentityOutput, error) {
+	if config, ok := m.(*ProviderConfig); ok {
+		return config.callerIdentity(ctx, sess)
+	}
+	return sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+}
+
+// stringList converts a schema list attribute into strings
+func stringList(raw interface{}) []string {
+	var out []string
+	for _, v := range raw.([]interface{}) {
+		if s, ok := v.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,292 @@
+package techniques
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// PipelineIdentity names the repository, project, workspace or job a plan
+// runs for, prefixed by the CI system (e.g., github:org/repo)
+type PipelineIdentity struct {
+	System string
+	Name   string
+}
+
+func (p PipelineIdentity) String() string {
+	return p.System + ":" + p.Name
+}
+
+// DetectPipelineIdentities reads CI identity variables from the environment
+func DetectPipelineIdentities() []PipelineIdentity {
+	env := GetEnvVars("")
+	var identities []PipelineIdentity
+
+	if repo := env["GITHUB_REPOSITORY"]; repo != "" {
+		identities = append(identities, PipelineIdentity{System: "github", Name: repo})
+	}
+	if project := env["CI_PROJECT_PATH"]; project != "" {
+		identities = append(identities, PipelineIdentity{System: "gitlab", Name: project})
+	}
+	if len(GetEnvVars("ATLANTIS_")) > 0 || env["BASE_REPO_NAME"] != "" {
+		identities = append(identities, PipelineIdentity{System: "atlantis", Name: joinOwner(env["BASE_REPO_OWNER"], env["BASE_REPO_NAME"])})
+	}
+	if workspace := env["TFC_WORKSPACE_NAME"]; workspace != "" {
+		identities = append(identities, PipelineIdentity{System: "tfc", Name: workspace})
+	}
+	if job := env["JOB_NAME"]; job != "" {
+		identities = append(identities, PipelineIdentity{System: "jenkins", Name: job})
+	}
+
+	return identities
+}
+
+// CheckPipelineScope refuses to run unless every detected pipeline identity
+// matches one of the allowlisted patterns. Patterns use path.Match syntax
+// against "system:name" (e.g., github:acme/*). An empty allowlist only allows
+// runs outside CI; a lone "*" allows any pipeline.
+func CheckPipelineScope(allowed []string) error {
+	if contains(allowed, "*") {
+		return nil
+	}
+
+	identities := DetectPipelineIdentities()
+	if len(allowed) == 0 {
+		if len(identities) > 0 {
+			return fmt.Errorf("pipeline %s detected but no pipeline allowlist is configured; list the in-scope pipelines, or \"*\" to allow any", identities[0])
+		}
+		return nil
+	}
+	if len(identities) == 0 {
+		return fmt.Errorf("no CI pipeline identity found in the environment and a pipeline allowlist is configured")
+	}
+
+	for _, identity := range identities {
+		if identity.Name == "" {
+			return fmt.Errorf("%s pipeline detected but its repository could not be determined", identity.System)
+		}
+		if !matchesAny(allowed, identity.String()) {
+			return fmt.Errorf("pipeline %s is not in the allowlist (%s)", identity, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Runner types for ExecutionContext
+const (
+	RunnerHosted     = "hosted"
+	RunnerSelfHosted = "self-hosted"
+	RunnerUnknown    = "unknown"
+)
+
+// ExecutionContext describes where a plan runs: the CI system, what triggered
+// it and on what kind of runner. It holds identifiers only, never secret values.
+type ExecutionContext struct {
+	System           string `json:"system,omitempty"`
+	Pipeline         string `json:"pipeline,omitempty"`
+	Event            string `json:"event,omitempty"`
+	ForkPR           bool   `json:"fork_pr,omitempty"`
+	Runner           string `json:"runner,omitempty"`
+	TerraformVersion string `json:"terraform_version,omitempty"`
+	WorkingDir       string `json:"working_dir,omitempty"`
+}
+
+func (c ExecutionContext) String() string {
+	system := c.System
+	if system == "" {
+		system = "local"
+	}
+
+	var b strings.Builder
+	b.WriteString(system)
+	if c.Pipeline != "" {
+		fmt.Fprintf(&b, " %s", c.Pipeline)
+	}
+	if c.Event != "" {
+		fmt.Fprintf(&b, " %s", c.Event)
+	}
+	if c.ForkPR {
+		b.WriteString(" from a fork")
+	}
+	if c.Runner != "" {
+		fmt.Fprintf(&b, " on a %s runner", c.Runner)
+	}
+	if c.TerraformVersion != "" {
+		fmt.Fprintf(&b, ", terraform %s", c.TerraformVersion)
+	}
+	if c.WorkingDir != "" {
+		fmt.Fprintf(&b, ", in %s", c.WorkingDir)
+	}
+	return b.String()
+}
+
+// DetectExecutionContext fingerprints the CI system from its environment
+// variables and event files. terraformVersion is what Terraform reported to
+// the provider; when empty it is read from version pin files instead.
+func DetectExecutionContext(terraformVersion string) ExecutionContext {
+	env := GetEnvVars("")
+	ec := ExecutionContext{Runner: RunnerUnknown}
+
+	switch {
+	case env["GITHUB_ACTIONS"] == "true":
+		ec.System = "github"
+		ec.Pipeline = env["GITHUB_REPOSITORY"]
+		ec.Event = env["GITHUB_EVENT_NAME"]
+		ec.ForkPR = githubForkPR(env["GITHUB_EVENT_PATH"])
+		switch env["RUNNER_ENVIRONMENT"] {
+		case "github-hosted":
+			ec.Runner = RunnerHosted
+		case "self-hosted":
+			ec.Runner = RunnerSelfHosted
+		}
+	case env["GITLAB_CI"] == "true":
+		ec.System = "gitlab"
+		ec.Pipeline = env["CI_PROJECT_PATH"]
+		ec.Event = env["CI_PIPELINE_SOURCE"]
+		source, target := env["CI_MERGE_REQUEST_SOURCE_PROJECT_ID"], env["CI_MERGE_REQUEST_PROJECT_ID"]
+		ec.ForkPR = source != "" && target != "" && source != target
+		ec.Runner = gitlabRunner(env)
+	case len(GetEnvVars("ATLANTIS_")) > 0 || env["BASE_REPO_NAME"] != "":
+		ec.System = "atlantis"
+		ec.Pipeline = joinOwner(env["BASE_REPO_OWNER"], env["BASE_REPO_NAME"])
+		ec.Event = "pull_request"
+		head := joinOwner(env["HEAD_REPO_OWNER"], env["HEAD_REPO_NAME"])
+		ec.ForkPR = head != "" && head != ec.Pipeline
+		ec.Runner = RunnerSelfHosted
+	case env["TFC_RUN_ID"] != "" || env["TFC_WORKSPACE_NAME"] != "":
+		ec.System = "tfc"
+		ec.Pipeline = env["TFC_WORKSPACE_NAME"]
+		ec.Event = "run"
+		ec.Runner = RunnerHosted
+		if len(GetEnvVars("TFC_AGENT")) > 0 {
+			ec.Runner = RunnerSelfHosted
+		}
+	case env["TF_VAR_spacelift_run_id"] != "" || len(GetEnvVars("SPACELIFT_")) > 0:
+		ec.System = "spacelift"
+		ec.Pipeline = env["TF_VAR_spacelift_stack_id"]
+		ec.Event = strings.ToLower(env["TF_VAR_spacelift_run_type"])
+	case env["ENV0_ENVIRONMENT_ID"] != "":
+		ec.System = "env0"
+		ec.Pipeline = joinOwner(env["ENV0_PROJECT_NAME"], env["ENV0_ENVIRONMENT_NAME"])
+		ec.Event = env["ENV0_DEPLOYMENT_TYPE"]
+		if env["ENV0_PR_NUMBER"] != "" {
+			ec.Event = "pull_request"
+		}
+	case env["JENKINS_URL"] != "" || env["JOB_NAME"] != "":
+		ec.System = "jenkins"
+		ec.Pipeline = env["JOB_NAME"]
+		ec.Event = "build"
+		if env["CHANGE_ID"] != "" {
+			ec.Event = "pull_request"
+		}
+		ec.ForkPR = env["CHANGE_FORK"] != ""
+		ec.Runner = RunnerSelfHosted
+	default:
+		ec.Runner = ""
+	}
+
+	ec.TerraformVersion = terraformVersion
+	if ec.TerraformVersion == "" {
+		ec.TerraformVersion = pinnedTerraformVersion(env)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		ec.WorkingDir = wd
+	}
+	return ec
+}
+
+// gitlabRunner tells GitLab.com hosted runners, which describe themselves as
+// *.saas-linux-*.runners-manager.gitlab.com or carry saas-* tags, from ones
+// the client operates. A self-managed instance only has client runners; on
+// GitLab.com an unrecognised runner is left unknown.
+func gitlabRunner(env map[string]string) string {
+	description := env["CI_RUNNER_DESCRIPTION"]
+	if strings.Contains(description, "runners-manager.gitlab.com") || strings.Contains(description, "shared-runners") {
+		return RunnerHosted
+	}
+	for _, tag := range strings.FieldsFunc(env["CI_RUNNER_TAGS"], func(r rune) bool { return strings.ContainsRune(`[]", `, r) }) {
+		if strings.HasPrefix(tag, "saas-") {
+			return RunnerHosted
+		}
+	}
+	if host := env["CI_SERVER_HOST"]; host != "" && host != "gitlab.com" {
+		return RunnerSelfHosted
+	}
+	return RunnerUnknown
+}
+
+// githubForkPR reads the event payload to tell whether a pull request comes
+// from a fork
+func githubForkPR(eventPath string) bool {
+	if eventPath == "" {
+		return false
+	}
+	content, err := os.ReadFile(eventPath)
+	if err != nil {
+		return false
+	}
+
+	var event struct {
+		PullRequest *struct {
+			Head struct {
+				Repo struct {
+					Fork     bool   `json:"fork"`
+					FullName string `json:"full_name"`
+				} `json:"repo"`
+			} `json:"head"`
+			Base struct {
+				Repo struct {
+					FullName string `json:"full_name"`
+				} `json:"repo"`
+			} `json:"base"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(content, &event); err != nil || event.PullRequest == nil {
+		return false
+	}
+	// repo.fork is true for every branch of a repository that is itself a
+	// fork, so only a different head repository marks a fork pull request
+	head, base := event.PullRequest.Head.Repo, event.PullRequest.Base.Repo
+	return head.FullName != "" && head.FullName != base.FullName
+}
+
+// pinnedTerraformVersion falls back to the version CI tools and version
+// managers pin, when Terraform did not report one
+func pinnedTerraformVersion(env map[string]string) string {
+	for _, name := range []string{"ATLANTIS_TERRAFORM_VERSION", "TFENV_TERRAFORM_VERSION", "TF_VERSION"} {
+		if v := env[name]; v != "" {
+			return v
+		}
+	}
+
+	if content, err := os.ReadFile(".terraform-version"); err == nil {
+		return strings.TrimSpace(string(content))
+	}
+	if content, err := os.ReadFile(".tool-versions"); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "terraform" {
+				return fields[1]
+			}
+		}
+	}
+	return ""
+}
+
+func joinOwner(owner, name string) string {
+	if owner == "" {
+		return name
+	}
+	return owner + "/" + name
+}
@@ -0,0 +1,141 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// ProviderConfig represents the provider configuration
+type ProviderConfig struct {
+	Client   *http.Client
+	Findings *Collector
+	Rotation *RotationManifest
+	// Journal records which techniques already ran against which targets
+	Journal *Journal
+
+	// AllowWriteTechniques permits techniques that change client infrastructure
+	AllowWriteTechniques bool
+	// AllowedTechniques limits which techniques may run; empty allows all
+	AllowedTechniques []string
+	// Engagement is the signed engagement config, if one was provided
+	Engagement *Engagement
+	// EngagementVerified is set when Engagement's signature was checked against the embedded key
+	EngagementVerified bool
+	// Version identifies the provider build
+	Version string
+	// Execution fingerprints the CI system the plan runs in; attached to every finding
+	Execution ExecutionContext
+
+	// Limiter paces cloud API calls across all techniques
+	Limiter *RateLimiter
+	// MaxTargets caps how many secrets, parameters or state files one technique touches; zero is unlimited
+	MaxTargets int
+	// CallBudget caps the cloud API calls one technique run may make; zero is unlimited
+	CallBudget int
+	// TechniqueTimeout bounds how long one technique run may take; zero is unbounded
+	TechniqueTimeout time.Duration
+
+	mu          sync.Mutex
+	awsIdentity *sts.GetCallerIdentityOutput
+}
+
+// authorize checks a technique against the engagement's gating settings.
+// Provider attributes can only narrow what a signed engagement config allows.
+func (c *ProviderConfig) authorize(t Technique) error {
+	if len(c.AllowedTechniques) > 0 && !contains(c.AllowedTechniques, t.Name) {
+		return fmt.Errorf("technique %s is not in allowed_techniques for this engagement", t.Name)
+	}
+
+	if t.SideEffect != SideEffectReadOnly && !c.AllowWriteTechniques {
+		return fmt.Errorf("technique %s is a %s technique and allow_write_techniques is not enabled for this engagement", t.Name, t.SideEffect)
+	}
+
+	if e := c.Engagement; e != nil {
+		if err := e.Active(time.Now()); err != nil {
+			return err
+		}
+		if len(e.AllowedTechniques) > 0 && !contains(e.AllowedTechniques, t.Name) {
+			return fmt.Errorf("technique %s is not allowed by engagement %s", t.Name, e.ID)
+		}
+		if t.SideEffect != SideEffectReadOnly && !e.AllowWriteTechniques {
+			return fmt.Errorf("technique %s is a %s technique and engagement %s does not allow write techniques", t.Name, t.SideEffect, e.ID)
+		}
+	}
+
+	return nil
+}
+
+// engagementID returns the engagement ID, or empty when no config was loaded
+func (c *ProviderConfig) engagementID() string {
+	if c.Engagement == nil {
+		return ""
+	}
+	return c.Engagement.ID
+}
+
+// readOnly reports whether this engagement forbids write techniques, in
+// which case techniques pick their least invasive defaults
+func (c *ProviderConfig) readOnly() bool {
+	return !c.AllowWriteTechniques || (c.Engagement != nil && !c.Engagement.AllowWriteTechniques)
+}
+
+// checkAwsScope refuses AWS credentials for accounts outside the engagement scope
+func (c *ProviderConfig) checkAwsScope(ctx context.Context, sess *session.Session) error {
+	if c.Engagement == nil || len(c.Engagement.Scope.AwsAccountIDs) == 0 {
+		return nil
+	}
+
+	identity, err := c.callerIdentity(ctx, sess)
+	if err != nil {
+		return fmt.Errorf("failed to resolve AWS caller identity for scope check: %v", err)
+	}
+
+	if !contains(c.Engagement.Scope.AwsAccountIDs, *identity.Account) {
+		return fmt.Errorf("AWS account %s is outside the scope of engagement %s", *identity.Account, c.Engagement.ID)
+	}
+	return nil
+}
+
+// checkGcpScope refuses GCP projects outside the engagement scope
+func (c *ProviderConfig) checkGcpScope(project string) error {
+	if c.Engagement == nil || len(c.Engagement.Scope.GcpProjects) == 0 {
+		return nil
+	}
+
+	if !contains(c.Engagement.Scope.GcpProjects, project) {
+		return fmt.Errorf("GCP project %s is outside the scope of engagement %s", project, c.Engagement.ID)
+	}
+	return nil
+}
+
+// callerIdentity resolves and caches the AWS identity the provider runs as
+func (c *ProviderConfig) callerIdentity(ctx context.Context, sess *session.Session) (*sts.GetCallerIdentityOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.awsIdentity != nil {
+		return c.awsIdentity, nil
+	}
+
+	identity, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+	c.awsIdentity = identity
+	return identity, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
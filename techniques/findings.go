@@ -0,0 +1,332 @@
+package techniques
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/rileydakota/tf-plan-recon/detector"
+)
+
+// Severity is the impact rating attached to a finding
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+	SeverityInfo     Severity = "info"
+)
+
+// Outcome says whether a finding shows exposure or a control that held
+type Outcome string
+
+const (
+	OutcomeExposed Outcome = "exposed"
+	OutcomeBlocked Outcome = "control-effective"
+)
+
+// Location points at the configuration block that invoked a technique
+type Location struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// Finding is a single result produced by a technique
+type Finding struct {
+	RuleID      string   `json:"rule_id"`
+	Technique   string   `json:"technique"`
+	Severity    Severity `json:"severity"`
+	Outcome     Outcome  `json:"outcome"`
+	Title       string   `json:"title"`
+	Message     string   `json:"message"`
+	Location    Location `json:"location"`
+	Remediation string   `json:"remediation"`
+	// Detections counts the kinds of credentials the finding exposed
+	Detections map[string]int `json:"detections,omitempty"`
+	// Context is the CI execution context the plan ran in
+	Context *ExecutionContext `json:"context,omitempty"`
+}
+
+// Collector accumulates findings from all techniques in the provider process.
+// Terraform reads data sources concurrently, so it also tracks technique runs
+// to know when the last one has finished.
+type Collector struct {
+	mu         sync.Mutex
+	findings   []Finding
+	reportPath string
+
+	expected int
+	running  int
+	finished int
+	emitted  bool
+}
+
+// NewCollector returns a collector that writes reports to reportPath, if set.
+// The number of technique runs to expect is taken from the technique data
+// blocks in the root module; see countTechniqueBlocks for what it misses.
+func NewCollector(reportPath string) *Collector {
+	return &Collector{
+		reportPath: reportPath,
+		expected:   countTechniqueBlocks("."),
+	}
+}
+
+// Add records findings and refreshes the report files so they are complete
+// whenever the last technique finishes
+func (c *Collector) Add(findings ...Finding) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.findings = append(c.findings, findings...)
+	if c.reportPath == "" {
+		return nil
+	}
+	return writeReports(c.reportPath, c.findings)
+}
+
+// Findings returns a copy of everything recorded so far
+func (c *Collector) Findings() []Finding {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]Finding, len(c.findings))
+	copy(out, c.findings)
+	return out
+}
+
+// Start marks a technique run as in flight
+func (c *Collector) Start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.running++
+}
+
+// Finish marks a technique run as done. When it was the last expected run,
+// it returns the consolidated summary diagnostic for the whole plan.
+func (c *Collector) Finish() *diag.Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.running--
+	c.finished++
+	if c.emitted || c.running > 0 || c.finished < c.expected {
+		return nil
+	}
+	c.emitted = true
+
+	return &diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Findings Summary",
+		Detail:   c.summary(),
+	}
+}
+
+// summary renders counts by severity and technique; callers hold c.mu
+func (c *Collector) summary() string {
+	severities := []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+	bySeverity := make(map[Severity]int)
+	exposed := make(map[string]int)
+	blocked := make(map[string]int)
+	var techniques []string
+
+	for _, f := range c.findings {
+		bySeverity[f.Severity]++
+		if exposed[f.Technique] == 0 && blocked[f.Technique] == 0 {
+			techniques = append(techniques, f.Technique)
+		}
+		if f.Outcome == OutcomeBlocked {
+			blocked[f.Technique]++
+		} else {
+			exposed[f.Technique]++
+		}
+	}
+	sort.Strings(techniques)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d findings from %d technique runs\n", len(c.findings), c.finished)
+
+	b.WriteString("By severity:")
+	for _, s := range severities {
+		fmt.Fprintf(&b, " %s=%d", s, bySeverity[s])
+	}
+	b.WriteString("\n")
+
+	if len(techniques) > 0 {
+		b.WriteString("By technique:\n")
+		for _, t := range techniques {
+			fmt.Fprintf(&b, "  %s: %d exposed, %d control effective\n", t, exposed[t], blocked[t])
+		}
+	}
+
+	if c.reportPath != "" {
+		fmt.Fprintf(&b, "Reports: %s, %s", filepath.Join(c.reportPath, "tfplanrecon.sarif"), filepath.Join(c.reportPath, "tfplanrecon.json"))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// newFinding builds a finding for a technique, filling in its rule and the
+// location of the data block that invoked it
+func newFinding(technique string, severity Severity, title, message string, hints ...string) Finding {
+	t, _ := LookupTechnique(technique)
+	return Finding{
+		RuleID:      t.RuleID,
+		Technique:   technique,
+		Severity:    severity,
+		Outcome:     OutcomeExposed,
+		Title:       title,
+		Message:     message,
+		Location:    locateDataBlock(".", technique, hints...),
+		Remediation: t.Remediation,
+	}
+}
+
+// withDetections attaches a credential tally to a finding and names what was
+// exposed in its message
+func withDetections(f Finding, tally detector.Tally) Finding {
+	if len(tally) == 0 {
+		return f
+	}
+	f.Detections = tally.Counts()
+	f.Message = fmt.Sprintf("%s. Contains %s", f.Message, tally)
+	return f
+}
+
+// recordFindings adds findings to the provider's collector. A failed report
+// write is surfaced as a warning since it should not abort the technique.
+func recordFindings(m interface{}, findings ...Finding) diag.Diagnostics {
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.Findings == nil {
+		return nil
+	}
+	for i := range findings {
+		if findings[i].Context == nil {
+			execution := config.Execution
+			findings[i].Context = &execution
+		}
+	}
+	if err := config.Findings.Add(findings...); err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Failed to Write Findings Report",
+			Detail:   fmt.Sprintf("Error writing report to %s: %v", config.Findings.reportPath, err),
+		}}
+	}
+	return nil
+}
+
+var dataBlockPattern = regexp.MustCompile(`^\s*data\s+"([^"]+)"\s+"([^"]+)"`)
+
+type dataBlock struct {
+	location Location
+	body     string
+}
+
+var literalCountPattern = regexp.MustCompile(`(?m)^\s*count\s*=\s*(\d+)\s*$`)
+
+// countTechniqueBlocks counts the technique reads the data blocks in dir will
+// make. A literal count is honoured; for_each and computed counts are taken
+// as one instance, and blocks in child modules are not seen at all, so the
+// result is a best effort and Finish emits the summary at most once.
+func countTechniqueBlocks(dir string) int {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+
+	count := 0
+	for _, file := range files {
+		for _, t := range registry {
+			for _, block := range scanDataBlocks(file, t.Name) {
+				if match := literalCountPattern.FindStringSubmatch(block.body); match != nil {
+					n, _ := strconv.Atoi(match[1])
+					count += n
+					continue
+				}
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// locateDataBlock finds the data block of the given type in the root module.
+// When several blocks share the type, the one whose body contains the most
+// hint values (the block's configured arguments) wins.
+func locateDataBlock(dir, dataSourceType string, hints ...string) Location {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	sort.Strings(files)
+
+	var blocks []dataBlock
+	for _, file := range files {
+		blocks = append(blocks, scanDataBlocks(file, dataSourceType)...)
+	}
+	if len(blocks) == 0 {
+		return Location{}
+	}
+
+	best, bestScore := 0, -1
+	for i, block := range blocks {
+		score := 0
+		for _, hint := range hints {
+			if hint != "" && strings.Contains(block.body, `"`+hint+`"`) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return blocks[best].location
+}
+
+func scanDataBlocks(file, dataSourceType string) []dataBlock {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var blocks []dataBlock
+	var current *dataBlock
+	var body strings.Builder
+	depth := 0
+	lineNo := 0
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+
+		if current == nil {
+			match := dataBlockPattern.FindStringSubmatch(line)
+			if match == nil || match[1] != dataSourceType {
+				continue
+			}
+			current = &dataBlock{location: Location{
+				File:    filepath.ToSlash(file),
+				Line:    lineNo,
+				Address: fmt.Sprintf("data.%s.%s", match[1], match[2]),
+			}}
+			body.Reset()
+			depth = 0
+		}
+
+		body.WriteString(line)
+		body.WriteString("\n")
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth <= 0 && strings.Contains(body.String(), "{") {
+			current.body = body.String()
+			blocks = append(blocks, *current)
+			current = nil
+		}
+	}
+	return blocks
+}
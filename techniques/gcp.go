@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -47,6 +48,12 @@ func gcpIamBindingRead(ctx context.Context, d *schema.ResourceData, m interface{
 		}
 	}
 	
+	if config, ok := m.(*ProviderConfig); ok {
+		if err := config.checkGcpScope(project); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	role := d.Get("role").(string)
 	member := d.Get("member").(string)
 	
@@ -56,53 +63,112 @@ func gcpIamBindingRead(ctx context.Context, d *schema.ResourceData, m interface{
 		Detail:   fmt.Sprintf("Creating IAM binding: Project=%s, Role=%s, Member=%s", project, role, member),
 	})
 	
+	target := fmt.Sprintf("%s/%s/%s", project, role, member)
+	executed, ok := alreadyExecuted(m, "tfplanrecon_gcp_iam_binding", target)
+	diags = append(diags, executed...)
+	if ok {
+		d.SetId(target)
+		return diags
+	}
+
 	service, err := cloudresourcemanager.NewService(ctx, option.WithScopes(cloudresourcemanager.CloudPlatformScope))
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("failed to create Cloud Resource Manager service: %v", err))
 	}
 	
-	policy, err := service.Projects.GetIamPolicy(project, &cloudresourcemanager.GetIamPolicyRequest{}).Do()
+	if err := spend(ctx, 1); err != nil {
+		return append(diags, partialResults(ctx, "IAM policy calls", 0))
+	}
+
+	// Version 3 returns conditional bindings, which a v1 write would drop
+	policy, err := service.Projects.GetIamPolicy(project, &cloudresourcemanager.GetIamPolicyRequest{
+		Options: &cloudresourcemanager.GetPolicyOptions{RequestedPolicyVersion: 3},
+	}).Context(ctx).Do()
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to get IAM policy: %v", err))
+		ce := classifyError(err)
+		if ce.Denied() {
+			f := controlFinding("tfplanrecon_gcp_iam_binding", "resourcemanager.projects.getIamPolicy", project, ce, role, member)
+			diags = append(diags, controlDiagnostic("resourcemanager.projects.getIamPolicy", f))
+			diags = append(diags, recordExecution(m, "tfplanrecon_gcp_iam_binding", target, f)...)
+			d.SetId(fmt.Sprintf("%s/%s/%s", project, role, member))
+			return diags
+		}
+		return diag.FromErr(fmt.Errorf("failed to get IAM policy (%s): %v", ce.Class, err))
 	}
 	
-	var binding *cloudresourcemanager.Binding
 	for _, b := range policy.Bindings {
-		if b.Role == role {
-			binding = b
-			break
+		if b.Role != role || !contains(b.Members, member) {
+			continue
 		}
-	}
-	
-	if binding == nil {
-		binding = &cloudresourcemanager.Binding{
-			Role:    role,
-			Members: []string{},
+
+		// The journal may be gone with the runner; the binding's condition
+		// says whether this engagement added it
+		if at, ok := conditionedByEngagement(b.Condition, m); ok {
+			diags = append(diags, executedDiagnostics("tfplanrecon_gcp_iam_binding", target, at, "condition on binding "+target)...)
+			diags = append(diags, recordExecution(m, "tfplanrecon_gcp_iam_binding", target, newFinding("tfplanrecon_gcp_iam_binding", SeverityCritical,
+				"GCP IAM Binding Created at Plan Time",
+				fmt.Sprintf("The plan identity added %s with role %s to project %s at %s", member, role, project, at.Format(time.RFC3339)),
+				role, member))...)
+			d.SetId(target)
+			return diags
 		}
-		policy.Bindings = append(policy.Bindings, binding)
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Member Already Has Role Binding",
+			Detail:   fmt.Sprintf("Member %s already has role %s in project %s", member, role, project),
+		})
+		diags = append(diags, recordExecution(m, "tfplanrecon_gcp_iam_binding", target, newFinding("tfplanrecon_gcp_iam_binding", SeverityInfo,
+			"GCP IAM Binding Already Present",
+			fmt.Sprintf("%s already held role %s in project %s before this engagement; no binding was added", member, role, project),
+			role, member))...)
+		d.SetId(target)
+		return diags
 	}
-	
-	for _, existingMember := range binding.Members {
-		if existingMember == member {
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Warning,
-				Summary:  "Member Already Has Role Binding",
-				Detail:   fmt.Sprintf("Member %s already has role %s in project %s", member, role, project),
-			})
-			d.SetId(fmt.Sprintf("%s/%s/%s", project, role, member))
-			return diags
+
+	// Basic roles do not accept conditions, so those bindings rely on the
+	// journal alone
+	if basicRole(role) {
+		var binding *cloudresourcemanager.Binding
+		for _, b := range policy.Bindings {
+			if b.Role == role && b.Condition == nil {
+				binding = b
+				break
+			}
+		}
+		if binding == nil {
+			binding = &cloudresourcemanager.Binding{Role: role}
+			policy.Bindings = append(policy.Bindings, binding)
 		}
+		binding.Members = append(binding.Members, member)
+	} else {
+		policy.Bindings = append(policy.Bindings, &cloudresourcemanager.Binding{
+			Role:      role,
+			Members:   []string{member},
+			Condition: engagementCondition(m),
+		})
 	}
-	
-	binding.Members = append(binding.Members, member)
-	
+	policy.Version = 3
+
 	setRequest := &cloudresourcemanager.SetIamPolicyRequest{
 		Policy: policy,
 	}
 	
-	_, err = service.Projects.SetIamPolicy(project, setRequest).Do()
+	if err := spend(ctx, 1); err != nil {
+		return append(diags, partialResults(ctx, "IAM policy calls", 1))
+	}
+
+	_, err = service.Projects.SetIamPolicy(project, setRequest).Context(ctx).Do()
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("failed to set IAM policy: %v", err))
+		ce := classifyError(err)
+		if ce.Denied() {
+			f := controlFinding("tfplanrecon_gcp_iam_binding", "resourcemanager.projects.setIamPolicy", project, ce, role, member)
+			diags = append(diags, controlDiagnostic("resourcemanager.projects.setIamPolicy", f))
+			diags = append(diags, recordExecution(m, "tfplanrecon_gcp_iam_binding", target, f)...)
+			d.SetId(fmt.Sprintf("%s/%s/%s", project, role, member))
+			return diags
+		}
+		return diag.FromErr(fmt.Errorf("failed to set IAM policy (%s): %v", ce.Class, err))
 	}
 	
 	diags = append(diags, diag.Diagnostic{
@@ -110,7 +176,18 @@ func gcpIamBindingRead(ctx context.Context, d *schema.ResourceData, m interface{
 		Summary:  "Successfully Added GCP IAM Binding",
 		Detail:   fmt.Sprintf("Added %s with role %s to project %s", member, role, project),
 	})
+
+	diags = append(diags, recordExecution(m, "tfplanrecon_gcp_iam_binding", target, newFinding("tfplanrecon_gcp_iam_binding", SeverityCritical,
+		"GCP IAM Binding Created at Plan Time",
+		fmt.Sprintf("The plan identity added %s with role %s to project %s", member, role, project),
+		role, member))...)
 	
 	d.SetId(fmt.Sprintf("%s/%s/%s", project, role, member))
 	return diags
-}
\ No newline at end of file
+}
+
+// basicRole reports whether role is one of the primitive roles, which IAM
+// conditions cannot be attached to
+func basicRole(role string) bool {
+	return role == "roles/owner" || role == "roles/editor" || role == "roles/viewer"
+}
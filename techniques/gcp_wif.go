@@ -0,0 +1,296 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	iam "google.golang.org/api/iam/v1"
+	"google.golang.org/api/option"
+)
+
+const workloadIdentityUser = "roles/iam.workloadIdentityUser"
+
+var (
+	// Claims that pin a federated identity to a repository or project
+	repositoryClaims = []string{"repository", "repository_id", "project_path", "project_id", "workspace_name", "workspace_id", "sub"}
+	// Claims that pin it to a branch, tag or run phase
+	refClaims = []string{"ref", "ref_type", "ref_protected", "environment", "job_workflow_ref", "run_phase"}
+	// Conditions that match repositories by prefix or pattern admit more than one
+	looseMatchPattern = regexp.MustCompile(`\.(startsWith|endsWith|contains|matches)\(`)
+)
+
+// GcpWorkloadIdentity returns the schema for the GCP workload identity federation audit
+func GcpWorkloadIdentity() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: gcpWorkloadIdentityRead,
+
+		Schema: map[string]*schema.Schema{
+			"project": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The GCP project ID (defaults to GOOGLE_CLOUD_PROJECT env var)",
+			},
+		},
+	}
+}
+
+func gcpWorkloadIdentityRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	project := d.Get("project").(string)
+	if project == "" {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+		if project == "" {
+			return diag.FromErr(fmt.Errorf("project must be specified or GOOGLE_CLOUD_PROJECT environment variable must be set"))
+		}
+	}
+
+	if config, ok := m.(*ProviderConfig); ok {
+		if err := config.checkGcpScope(project); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON GCP Workload Identity Federation Audit",
+		Detail:   fmt.Sprintf("Listing workload identity pools, providers and service account bindings in project %s", project),
+	})
+
+	service, err := iam.NewService(ctx, option.WithScopes(iam.CloudPlatformScope))
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to create IAM service: %v", err))
+	}
+
+	providers, err := listWorkloadIdentityProviders(ctx, service, project)
+	if err != nil {
+		ce := classifyError(err)
+		switch {
+		case ce.Denied():
+			diags = append(diags, recordControl(m, "tfplanrecon_gcp_workload_identity", "iam.workloadIdentityPools.list", project, ce, project)...)
+			d.SetId(fmt.Sprintf("%s/wif-denied", project))
+			return diags
+		case ce.Class == ErrorCanceled:
+			diags = append(diags, partialResults(ctx, "workload identity providers listed", len(providers)))
+		default:
+			return diag.FromErr(fmt.Errorf("failed to list workload identity pools (%s): %v", ce.Class, err))
+		}
+	}
+
+	if len(providers) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No Workload Identity Providers Found",
+			Detail:   fmt.Sprintf("Project %s has no workload identity pool providers", project),
+		})
+		d.SetId(fmt.Sprintf("%s/no-wif", project))
+		return diags
+	}
+
+	var findings []Finding
+	var lines []string
+	for _, p := range providers {
+		issuer := ""
+		if p.Oidc != nil {
+			issuer = p.Oidc.IssuerUri
+		}
+		lines = append(lines, fmt.Sprintf("Provider %s (issuer %s): condition=%q mapping=%v", p.Name, issuer, p.AttributeCondition, p.AttributeMapping))
+
+		if severity, detail := wifConditionWeakness(p.AttributeCondition, issuer, p.AttributeMapping); detail != "" {
+			findings = append(findings, newFinding("tfplanrecon_gcp_workload_identity", severity,
+				"Workload Identity Provider Admits Any Repository",
+				fmt.Sprintf("Provider %s for issuer %s %s", p.Name, issuer, detail),
+				project))
+		}
+	}
+
+	bindings, err := workloadIdentityUserBindings(ctx, m, service, project)
+	if err != nil {
+		ce := classifyError(err)
+		switch {
+		case ce.Denied():
+			diags = append(diags, recordControl(m, "tfplanrecon_gcp_workload_identity", "iam.serviceAccounts.getIamPolicy", project, ce, project)...)
+		case ce.Class == ErrorCanceled:
+			diags = append(diags, partialResults(ctx, "service account policies read", len(bindings)))
+		default:
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Service Account Bindings Incomplete",
+				Detail:   fmt.Sprintf("Stopped reading service account policies (%s): %v", ce.Class, err),
+			})
+		}
+	}
+
+	for _, b := range bindings {
+		lines = append(lines, fmt.Sprintf("Service account %s: %s granted to %s", b.ServiceAccount, workloadIdentityUser, b.Member))
+		if severity, detail := wifMemberWeakness(b.Member); detail != "" {
+			findings = append(findings, newFinding("tfplanrecon_gcp_workload_identity", severity,
+				"Service Account Impersonable by Broad Principal Set",
+				fmt.Sprintf("Service account %s grants %s to %s, which %s", b.ServiceAccount, workloadIdentityUser, b.Member, detail),
+				project))
+		}
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "GCP Workload Identity Federation Audited",
+		Detail:   fmt.Sprintf("Found %d providers and %d pool bindings in project %s:\n%s", len(providers), len(bindings), project, strings.Join(lines, "\n")),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("%s/wif-%d", project, len(providers)))
+	return diags
+}
+
+func listWorkloadIdentityProviders(ctx context.Context, service *iam.Service, project string) ([]*iam.WorkloadIdentityPoolProvider, error) {
+	var pools []*iam.WorkloadIdentityPool
+	token := ""
+	for {
+		if err := spend(ctx, 1); err != nil {
+			return nil, err
+		}
+		resp, err := service.Projects.Locations.WorkloadIdentityPools.List(fmt.Sprintf("projects/%s/locations/global", project)).PageToken(token).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, resp.WorkloadIdentityPools...)
+		if token = resp.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	var providers []*iam.WorkloadIdentityPoolProvider
+	for _, pool := range pools {
+		token := ""
+		for {
+			if err := spend(ctx, 1); err != nil {
+				return providers, err
+			}
+			resp, err := service.Projects.Locations.WorkloadIdentityPools.Providers.List(pool.Name).PageToken(token).Context(ctx).Do()
+			if err != nil {
+				return providers, err
+			}
+			providers = append(providers, resp.WorkloadIdentityPoolProviders...)
+			if token = resp.NextPageToken; token == "" {
+				break
+			}
+		}
+	}
+	return providers, nil
+}
+
+// wifBinding is a workloadIdentityUser grant on a service account to a pool principal
+type wifBinding struct {
+	ServiceAccount string
+	Member         string
+}
+
+func workloadIdentityUserBindings(ctx context.Context, m interface{}, service *iam.Service, projectID string) ([]wifBinding, error) {
+	var accounts []*iam.ServiceAccount
+	token := ""
+	limit := maxTargets(m)
+	for {
+		if err := spend(ctx, 1); err != nil {
+			return nil, err
+		}
+		resp, err := service.Projects.ServiceAccounts.List("projects/" + projectID).PageToken(token).Context(ctx).Do()
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, resp.Accounts...)
+		if token = resp.NextPageToken; token == "" || (limit > 0 && len(accounts) >= limit) {
+			break
+		}
+	}
+	if limit > 0 && len(accounts) > limit {
+		accounts = accounts[:limit]
+	}
+
+	if err := project(ctx, len(accounts), "service account IAM policy reads"); err != nil {
+		return nil, err
+	}
+
+	var bindings []wifBinding
+	for _, account := range accounts {
+		if err := spend(ctx, 1); err != nil {
+			return bindings, err
+		}
+		policy, err := service.Projects.ServiceAccounts.GetIamPolicy(account.Name).Context(ctx).Do()
+		if err != nil {
+			if classifyError(err).Denied() {
+				continue
+			}
+			return bindings, err
+		}
+		for _, b := range policy.Bindings {
+			if b.Role != workloadIdentityUser {
+				continue
+			}
+			for _, member := range b.Members {
+				if strings.Contains(member, "workloadIdentityPools/") {
+					bindings = append(bindings, wifBinding{ServiceAccount: account.Email, Member: member})
+				}
+			}
+		}
+	}
+	return bindings, nil
+}
+
+// wifConditionWeakness explains why a provider's attribute condition admits
+// tokens from repositories or branches the client does not own
+func wifConditionWeakness(condition, issuer string, mapping map[string]string) (Severity, string) {
+	public := strings.Contains(issuer, "token.actions.githubusercontent.com") ||
+		strings.Contains(issuer, "gitlab.com") ||
+		strings.Contains(issuer, "app.terraform.io")
+
+	switch {
+	case strings.TrimSpace(condition) == "" && public:
+		return SeverityCritical, "has no attribute_condition, so tokens from any repository on the shared issuer are accepted"
+	case strings.TrimSpace(condition) == "":
+		return SeverityHigh, "has no attribute_condition, so every identity the issuer vouches for is accepted"
+	case !claimReferenced(condition, repositoryClaims, mapping):
+		return SeverityHigh, fmt.Sprintf("has attribute_condition %q that does not pin a repository or project, so any repository of the owner is accepted", condition)
+	case looseMatchPattern.MatchString(condition):
+		return SeverityMedium, fmt.Sprintf("has attribute_condition %q that matches repositories by prefix or pattern", condition)
+	case !claimReferenced(condition, refClaims, mapping):
+		return SeverityMedium, fmt.Sprintf("has attribute_condition %q that admits any branch, tag or pull request of the repository", condition)
+	}
+	return "", ""
+}
+
+// claimReferenced reports whether condition reads one of the token claims,
+// either directly as assertion.<claim> or through an attribute whose
+// attribute_mapping source reads it, such as attribute.repository
+func claimReferenced(condition string, claims []string, mapping map[string]string) bool {
+	var names []string
+	for _, claim := range claims {
+		names = append(names, regexp.QuoteMeta("assertion."+claim))
+	}
+	source := regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+
+	for attribute, expression := range mapping {
+		if source.MatchString(expression) {
+			names = append(names, regexp.QuoteMeta(attribute))
+		}
+	}
+	return regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`).MatchString(condition)
+}
+
+// wifMemberWeakness explains why a pool principal grants impersonation to
+// more identities than one pipeline
+func wifMemberWeakness(member string) (Severity, string) {
+	switch {
+	case strings.HasPrefix(member, "principalSet://") && strings.HasSuffix(member, "/*"):
+		return SeverityHigh, "is every identity in the pool"
+	case strings.Contains(member, "/attribute.repository_owner/") || strings.Contains(member, "/attribute.namespace_path/"):
+		return SeverityMedium, "is any repository of the owner"
+	case strings.Contains(member, "/group/"):
+		return SeverityMedium, "is a group of pool identities"
+	}
+	return "", ""
+}
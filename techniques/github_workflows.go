@@ -0,0 +1,444 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	planCommandPattern  = regexp.MustCompile(`\b(terraform|tofu|terragrunt)\b[^\n|;&]*\bplan\b`)
+	applyCommandPattern = regexp.MustCompile(`\b(terraform|tofu|terragrunt)\b[^\n|;&]*\bapply\b`)
+	secretRefPattern    = regexp.MustCompile(`\$\{\{\s*secrets\.([A-Za-z0-9_]+)\s*\}\}`)
+	forkGuardPattern    = regexp.MustCompile(`head\.repo\.(full_name\s*==\s*github\.repository|fork\s*==\s*false)|!\s*github\.event\.pull_request\.head\.repo\.fork`)
+)
+
+// prTriggers are the workflow events that run for pull requests
+var prTriggers = []string{"pull_request", "pull_request_target"}
+
+// GithubWorkflows returns the schema for the GitHub Actions workflow analyzer
+func GithubWorkflows() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: githubWorkflowsRead,
+
+		Schema: map[string]*schema.Schema{
+			"search_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     ".",
+				Description: "Path to search for .github/workflows directories",
+			},
+		},
+	}
+}
+
+// workflowJob is what the analyzer needs from one job of a workflow
+type workflowJob struct {
+	Name         string
+	Line         int
+	Plan         bool
+	Apply        bool
+	OIDC         bool
+	ForkGuarded  bool
+	HeadCheckout bool
+	// Credentials names the cloud identities and secrets the job receives
+	Credentials []string
+	// Roles are the assumable identities (role ARNs, service accounts)
+	Roles []string
+}
+
+// workflow is a parsed workflow file
+type workflow struct {
+	File     string
+	Triggers map[string]int
+	Jobs     []workflowJob
+}
+
+func githubWorkflowsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	searchPath := d.Get("search_path").(string)
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON GitHub Actions Workflow Analysis",
+		Detail:   fmt.Sprintf("Scanning for GitHub Actions workflows under %s", searchPath),
+	})
+
+	files, err := findWorkflowFiles(ctx, searchPath, ".github/workflows")
+	if err != nil && ctx.Err() != nil {
+		diags = append(diags, partialResults(ctx, "workflow files found", len(files)))
+		d.SetId("workflows-canceled")
+		return diags
+	}
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to scan for workflows: %v", err))
+	}
+
+	if len(files) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No GitHub Actions Workflows Found",
+			Detail:   fmt.Sprintf("No .github/workflows/*.yml files found under %s", searchPath),
+		})
+		d.SetId("no-workflows")
+		return diags
+	}
+
+	var workflows []workflow
+	for _, file := range files {
+		wf, err := parseWorkflow(file)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Failed to parse workflow: %s", file),
+				Detail:   err.Error(),
+			})
+			continue
+		}
+		workflows = append(workflows, wf)
+	}
+
+	findings := analyzeWorkflows(workflows)
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, fmt.Sprintf("%s:%d [%s] %s", f.Location.File, f.Location.Line, f.Severity, f.Message))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No plan-on-PR exposure found")
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "GitHub Actions Workflows Analyzed",
+		Detail:   fmt.Sprintf("Analyzed %d workflows:\n%s", len(workflows), strings.Join(lines, "\n")),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("workflows-%d", len(workflows)))
+	return diags
+}
+
+// findWorkflowFiles walks searchPath for YAML files inside directories
+// ending in dir (e.g. .github/workflows)
+func findWorkflowFiles(ctx context.Context, searchPath, dir string) ([]string, error) {
+	return findConfigFiles(ctx, searchPath, func(path string) bool {
+		ext := filepath.Ext(path)
+		return (ext == ".yml" || ext == ".yaml") && strings.HasSuffix(filepath.ToSlash(filepath.Dir(path)), dir)
+	})
+}
+
+// findConfigFiles walks searchPath for files accepted by match, skipping
+// provider caches and dependency trees
+func findConfigFiles(ctx context.Context, searchPath string, match func(path string) bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == ".terraform" || info.Name() == "node_modules" || (info.Name() == ".git" && path != searchPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if match(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// parseWorkflow reads the triggers and jobs of a workflow, keeping line
+// numbers so findings point at the exact place to fix
+func parseWorkflow(file string) (workflow, error) {
+	wf := workflow{File: filepath.ToSlash(file), Triggers: make(map[string]int)}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return wf, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return wf, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return wf, fmt.Errorf("workflow is not a mapping")
+	}
+	root := doc.Content[0]
+
+	if key, on := mappingValue(root, "on"); on != nil {
+		switch on.Kind {
+		case yaml.ScalarNode:
+			wf.Triggers[on.Value] = key.Line
+		case yaml.SequenceNode:
+			for _, item := range on.Content {
+				wf.Triggers[item.Value] = item.Line
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(on.Content); i += 2 {
+				wf.Triggers[on.Content[i].Value] = on.Content[i].Line
+			}
+		}
+	}
+
+	_, workflowPerms := mappingValue(root, "permissions")
+	workflowEnv := secretNames(root, "env")
+
+	_, jobs := mappingValue(root, "jobs")
+	if jobs == nil || jobs.Kind != yaml.MappingNode {
+		return wf, nil
+	}
+
+	for i := 0; i+1 < len(jobs.Content); i += 2 {
+		name, body := jobs.Content[i], jobs.Content[i+1]
+		if body.Kind != yaml.MappingNode {
+			continue
+		}
+		wf.Jobs = append(wf.Jobs, parseWorkflowJob(name, body, workflowPerms, workflowEnv))
+	}
+	return wf, nil
+}
+
+func parseWorkflowJob(name, body, workflowPerms *yaml.Node, workflowEnv []string) workflowJob {
+	job := workflowJob{Name: name.Value, Line: name.Line}
+
+	perms := workflowPerms
+	if _, jobPerms := mappingValue(body, "permissions"); jobPerms != nil {
+		perms = jobPerms
+	}
+	job.OIDC = grantsIDToken(perms)
+
+	if _, cond := mappingValue(body, "if"); cond != nil {
+		job.ForkGuarded = forkGuardPattern.MatchString(cond.Value)
+	}
+
+	for _, secret := range workflowEnv {
+		job.Credentials = appendUnique(job.Credentials, "secret "+secret)
+	}
+	for _, secret := range secretNames(body, "env") {
+		job.Credentials = appendUnique(job.Credentials, "secret "+secret)
+	}
+
+	_, steps := mappingValue(body, "steps")
+	if steps == nil || steps.Kind != yaml.SequenceNode {
+		return job
+	}
+
+	for _, step := range steps.Content {
+		if step.Kind != yaml.MappingNode {
+			continue
+		}
+
+		_, run := mappingValue(step, "run")
+		_, uses := mappingValue(step, "uses")
+		if run != nil {
+			job.Plan = job.Plan || planCommandPattern.MatchString(run.Value)
+			job.Apply = job.Apply || applyCommandPattern.MatchString(run.Value)
+		}
+		for _, secret := range secretNames(step, "env") {
+			job.Credentials = appendUnique(job.Credentials, "secret "+secret)
+		}
+		if uses == nil {
+			continue
+		}
+
+		action := strings.ToLower(strings.SplitN(uses.Value, "@", 2)[0])
+		_, with := mappingValue(step, "with")
+		switch {
+		case strings.Contains(action, "terraform-plan"):
+			job.Plan = true
+		case strings.Contains(action, "terraform-apply"):
+			job.Apply = true
+		case action == "actions/checkout":
+			if _, ref := mappingValue(with, "ref"); ref != nil && strings.Contains(ref.Value, "pull_request.head") {
+				job.HeadCheckout = true
+			}
+		case action == "aws-actions/configure-aws-credentials":
+			if _, role := mappingValue(with, "role-to-assume"); role != nil {
+				job.Roles = appendUnique(job.Roles, role.Value)
+				job.Credentials = appendUnique(job.Credentials, "AWS role "+role.Value)
+			}
+			if _, key := mappingValue(with, "aws-access-key-id"); key != nil {
+				job.Roles = appendUnique(job.Roles, key.Value)
+				job.Credentials = appendUnique(job.Credentials, "AWS access key "+key.Value)
+			}
+		case action == "google-github-actions/auth":
+			if _, sa := mappingValue(with, "service_account"); sa != nil {
+				job.Roles = appendUnique(job.Roles, sa.Value)
+				job.Credentials = appendUnique(job.Credentials, "GCP service account "+sa.Value)
+			}
+			if _, key := mappingValue(with, "credentials_json"); key != nil {
+				job.Roles = appendUnique(job.Roles, key.Value)
+				job.Credentials = appendUnique(job.Credentials, "GCP key "+key.Value)
+			}
+		case action == "azure/login":
+			if _, client := mappingValue(with, "client-id"); client != nil {
+				job.Roles = appendUnique(job.Roles, client.Value)
+				job.Credentials = appendUnique(job.Credentials, "Azure client "+client.Value)
+			}
+		}
+	}
+	return job
+}
+
+// analyzeWorkflows turns parsed workflows into plan-on-PR exposure findings
+func analyzeWorkflows(workflows []workflow) []Finding {
+	const technique = "tfplanrecon_github_workflows"
+	var findings []Finding
+
+	planRoles := make(map[string][]string)
+	applyRoles := make(map[string][]string)
+
+	for _, wf := range workflows {
+		prTrigger := ""
+		for _, trigger := range prTriggers {
+			if _, ok := wf.Triggers[trigger]; ok {
+				prTrigger = trigger
+			}
+		}
+
+		for _, job := range wf.Jobs {
+			where := fmt.Sprintf("%s:%d", wf.File, job.Line)
+			for _, role := range job.Roles {
+				if job.Plan {
+					planRoles[role] = append(planRoles[role], where)
+				}
+				if job.Apply {
+					applyRoles[role] = append(applyRoles[role], where)
+				}
+			}
+
+			if !job.Plan || prTrigger == "" {
+				continue
+			}
+
+			if prTrigger == "pull_request_target" {
+				severity, detail := SeverityHigh, "with the base repository's secrets and a write token"
+				if job.HeadCheckout {
+					severity, detail = SeverityCritical, "after checking out the pull request head, so a fork's Terraform code runs with the base repository's secrets"
+				}
+				findings = append(findings, workflowFinding(technique, severity,
+					"Terraform Plan on pull_request_target",
+					fmt.Sprintf("Job %s in %s runs terraform plan on pull_request_target %s", job.Name, wf.File, detail),
+					wf.File, wf.Triggers["pull_request_target"], job.Name))
+			} else if !job.ForkGuarded {
+				findings = append(findings, workflowFinding(technique, SeverityMedium,
+					"Terraform Plan Runs for Fork Pull Requests",
+					fmt.Sprintf("Job %s in %s runs terraform plan on pull_request without restricting fork pull requests", job.Name, wf.File),
+					wf.File, job.Line, job.Name))
+			}
+
+			if job.OIDC || len(job.Credentials) > 0 {
+				var passed []string
+				if job.OIDC {
+					passed = append(passed, "an OIDC token (id-token: write)")
+				}
+				passed = append(passed, job.Credentials...)
+				findings = append(findings, workflowFinding(technique, SeverityHigh,
+					"Cloud Credentials Passed to PR Plan Job",
+					fmt.Sprintf("Job %s in %s runs terraform plan on %s with %s; any pull request can run provider code with these credentials", job.Name, wf.File, prTrigger, strings.Join(passed, ", ")),
+					wf.File, job.Line, job.Name))
+			}
+		}
+	}
+
+	var shared []string
+	for role := range planRoles {
+		if _, ok := applyRoles[role]; ok {
+			shared = append(shared, role)
+		}
+	}
+	sort.Strings(shared)
+	for _, role := range shared {
+		plan := planRoles[role][0]
+		file, line := splitFileLine(plan)
+		findings = append(findings, workflowFinding(technique, SeverityHigh,
+			"Plan and Apply Share a Cloud Identity",
+			fmt.Sprintf("%s is used by plan jobs (%s) and apply jobs (%s); a plan-time compromise gets apply's privileges", role, strings.Join(planRoles[role], ", "), strings.Join(applyRoles[role], ", ")),
+			file, line, ""))
+	}
+	return findings
+}
+
+// workflowFinding points a finding at a line of a workflow file rather than
+// at the data block that invoked the technique
+func workflowFinding(technique string, severity Severity, title, message, file string, line int, job string) Finding {
+	f := newFinding(technique, severity, title, message)
+	f.Location = Location{File: file, Line: line}
+	if job != "" {
+		f.Location.Address = "jobs." + job
+	}
+	return f
+}
+
+// mappingValue returns the key and value nodes for key in a mapping node
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// secretNames lists the secrets referenced by the env mapping under key
+func secretNames(node *yaml.Node, key string) []string {
+	_, env := mappingValue(node, key)
+	if env == nil || env.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var names []string
+	for i := 1; i < len(env.Content); i += 2 {
+		for _, match := range secretRefPattern.FindAllStringSubmatch(env.Content[i].Value, -1) {
+			if match[1] != "GITHUB_TOKEN" {
+				names = appendUnique(names, match[1])
+			}
+		}
+	}
+	return names
+}
+
+// grantsIDToken reports whether a permissions block lets the job mint OIDC tokens
+func grantsIDToken(perms *yaml.Node) bool {
+	if perms == nil {
+		return false
+	}
+	if perms.Kind == yaml.ScalarNode {
+		return perms.Value == "write-all"
+	}
+	_, idToken := mappingValue(perms, "id-token")
+	return idToken != nil && idToken.Value == "write"
+}
+
+func appendUnique(list []string, v string) []string {
+	if contains(list, v) {
+		return list
+	}
+	return append(list, v)
+}
+
+func splitFileLine(where string) (string, int) {
+	i := strings.LastIndex(where, ":")
+	line := 0
+	fmt.Sscanf(where[i+1:], "%d", &line)
+	return where[:i], line
+}
@@ -0,0 +1,417 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	mergeRequestPattern   = regexp.MustCompile(`\$CI_PIPELINE_SOURCE\s*==\s*["']merge_request_event["']|\$CI_MERGE_REQUEST_`)
+	gitlabForkGuard       = regexp.MustCompile(`\$CI_MERGE_REQUEST_SOURCE_PROJECT_ID\s*==\s*\$CI_PROJECT_ID|\$CI_PROJECT_ID\s*==\s*\$CI_MERGE_REQUEST_SOURCE_PROJECT_ID`)
+	credentialVarPattern  = regexp.MustCompile(`\$\{?((AWS|ARM|GOOGLE|GCP|AZURE|TF_TOKEN|TFE|VAULT|CLOUDFLARE|DATADOG|GITHUB|GITLAB)[A-Z0-9_]*(KEY|SECRET|TOKEN|CREDENTIALS|PASSWORD)[A-Z0-9_]*)\}?`)
+	gitlabReservedKeys    = []string{"stages", "variables", "default", "include", "workflow", "image", "services", "before_script", "after_script", "cache", "spec"}
+	atlantisRepoConfigEnv = []string{"ATLANTIS_REPO_CONFIG", "ATLANTIS_REPO_CONFIG_JSON"}
+)
+
+// GitlabAtlantisConfig returns the schema for the GitLab CI and Atlantis
+// configuration analyzer
+func GitlabAtlantisConfig() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: gitlabAtlantisConfigRead,
+
+		Schema: map[string]*schema.Schema{
+			"search_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     ".",
+				Description: "Path to search for .gitlab-ci.yml, atlantis.yaml and Atlantis server repo configs (repos.yaml)",
+			},
+		},
+	}
+}
+
+func gitlabAtlantisConfigRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	searchPath := d.Get("search_path").(string)
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON GitLab CI and Atlantis Configuration Analysis",
+		Detail:   fmt.Sprintf("Scanning for GitLab CI and Atlantis configuration under %s", searchPath),
+	})
+
+	files, err := findConfigFiles(ctx, searchPath, func(path string) bool {
+		switch filepath.Base(path) {
+		case ".gitlab-ci.yml", "atlantis.yaml", "atlantis.yml", "repos.yaml", "repos.yml":
+			return true
+		}
+		return false
+	})
+	if err != nil && ctx.Err() != nil {
+		diags = append(diags, partialResults(ctx, "configuration files found", len(files)))
+		d.SetId("ci-config-canceled")
+		return diags
+	}
+	if err != nil {
+		return diag.FromErr(fmt.Errorf("failed to scan for CI configuration: %v", err))
+	}
+
+	// Inside an Atlantis server the repo config may only exist as a flag
+	var sources []yamlSource
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		sources = append(sources, yamlSource{Name: filepath.ToSlash(file), Content: content})
+	}
+	env := GetEnvVars("ATLANTIS_REPO_CONFIG")
+	for _, name := range atlantisRepoConfigEnv {
+		switch {
+		case env[name] == "":
+		case strings.HasSuffix(name, "_JSON"):
+			sources = append(sources, yamlSource{Name: "$" + name, Content: []byte(env[name]), Server: true})
+		default:
+			if content, err := os.ReadFile(env[name]); err == nil {
+				sources = append(sources, yamlSource{Name: filepath.ToSlash(env[name]), Content: content, Server: true})
+			}
+		}
+	}
+
+	if len(sources) == 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No GitLab CI or Atlantis Configuration Found",
+			Detail:   fmt.Sprintf("No .gitlab-ci.yml, atlantis.yaml or Atlantis repo config found under %s", searchPath),
+		})
+		d.SetId("no-ci-config")
+		return diags
+	}
+
+	var findings []Finding
+	for _, source := range sources {
+		var doc yaml.Node
+		base := filepath.Base(source.Name)
+		serverConfig := source.Server || strings.HasPrefix(base, "repos.")
+		if err := yaml.Unmarshal(source.Content, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			if serverConfig && !source.Server {
+				continue // Some other tool's repos.yaml
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Failed to parse configuration: %s", source.Name),
+				Detail:   fmt.Sprintf("Not a YAML mapping: %v", err),
+			})
+			continue
+		}
+		root := doc.Content[0]
+
+		switch {
+		case base == ".gitlab-ci.yml":
+			findings = append(findings, analyzeGitlabCI(source.Name, root)...)
+		case serverConfig:
+			if !source.Server && !isAtlantisServerConfig(root) {
+				continue
+			}
+			findings = append(findings, analyzeAtlantisServerConfig(source.Name, root)...)
+		default:
+			findings = append(findings, analyzeAtlantisRepoConfig(source.Name, root)...)
+		}
+	}
+
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, fmt.Sprintf("%s:%d [%s] %s", f.Location.File, f.Location.Line, f.Severity, f.Message))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "No plan exposure found")
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "GitLab CI and Atlantis Configuration Analyzed",
+		Detail:   fmt.Sprintf("Analyzed %d configuration sources:\n%s", len(sources), strings.Join(lines, "\n")),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("ci-config-%d", len(sources)))
+	return diags
+}
+
+// yamlSource is a configuration document read from a file or the environment
+type yamlSource struct {
+	Name    string
+	Content []byte
+	// Server marks Atlantis server-side repo config passed through flags
+	Server bool
+}
+
+// analyzeGitlabCI flags plan jobs that run for merge requests with
+// credentials available to them
+func analyzeGitlabCI(file string, root *yaml.Node) []Finding {
+	const technique = "tfplanrecon_gitlab_atlantis_config"
+	var findings []Finding
+
+	workflowMR := false
+	if _, wf := mappingValue(root, "workflow"); wf != nil {
+		workflowMR = mergeRequestPattern.MatchString(nodeText(wf))
+	}
+	globalCreds := credentialVariables(root)
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, job := root.Content[i], root.Content[i+1]
+		if contains(gitlabReservedKeys, name.Value) || strings.HasPrefix(name.Value, ".") || job.Kind != yaml.MappingNode {
+			continue
+		}
+
+		scripts := ""
+		for _, key := range []string{"before_script", "script"} {
+			if _, s := mappingValue(job, key); s != nil {
+				scripts += nodeText(s)
+			}
+		}
+		if !planCommandPattern.MatchString(scripts) {
+			continue
+		}
+
+		rules := ""
+		for _, key := range []string{"rules", "only"} {
+			if _, r := mappingValue(job, key); r != nil {
+				rules += nodeText(r)
+			}
+		}
+		forMRs := mergeRequestPattern.MatchString(rules) || strings.Contains(rules, "merge_requests") || (rules == "" && workflowMR)
+		if !forMRs {
+			continue
+		}
+
+		var passed []string
+		if _, tokens := mappingValue(job, "id_tokens"); tokens != nil {
+			passed = append(passed, "OIDC id_tokens")
+		}
+		if _, secrets := mappingValue(job, "secrets"); secrets != nil {
+			passed = append(passed, "CI/CD secrets")
+		}
+		for _, v := range globalCreds {
+			passed = appendUnique(passed, "$"+v)
+		}
+		for _, v := range credentialVariables(job) {
+			passed = appendUnique(passed, "$"+v)
+		}
+		for _, match := range credentialVarPattern.FindAllStringSubmatch(scripts, -1) {
+			passed = appendUnique(passed, "$"+match[1])
+		}
+
+		if !gitlabForkGuard.MatchString(rules) {
+			findings = append(findings, ciConfigFinding(technique, SeverityMedium,
+				"Terraform Plan Runs for Fork Merge Requests",
+				fmt.Sprintf("Job %s in %s runs terraform plan for merge requests without restricting them to the project's own branches", name.Value, file),
+				file, name.Line, "jobs."+name.Value))
+		}
+		if len(passed) > 0 {
+			findings = append(findings, ciConfigFinding(technique, SeverityHigh,
+				"Credentials Available to Merge Request Plan Job",
+				fmt.Sprintf("Job %s in %s runs terraform plan for merge requests with %s; if these are protected variables, any merge request from a protected source branch runs provider code with them", name.Value, file, strings.Join(passed, ", ")),
+				file, name.Line, "jobs."+name.Value))
+		}
+	}
+	return findings
+}
+
+// isAtlantisServerConfig tells an Atlantis repos.yaml from other files of
+// the same name: it has a top-level repos sequence of entries with an id
+func isAtlantisServerConfig(root *yaml.Node) bool {
+	_, repos := mappingValue(root, "repos")
+	if repos == nil || repos.Kind != yaml.SequenceNode || len(repos.Content) == 0 {
+		return false
+	}
+	for _, repo := range repos.Content {
+		if _, id := mappingValue(repo, "id"); id == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// atlantisIDCovers reports whether the repos entry id pattern matches every
+// repo that id does. Ids are exact repo names or /regex/; a regex covers an
+// exact name it matches, and only a catch-all is known to cover another regex.
+func atlantisIDCovers(pattern, id string) bool {
+	if pattern == id {
+		return true
+	}
+	if len(pattern) < 2 || !strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return false
+	}
+	expr := pattern[1 : len(pattern)-1]
+	switch expr {
+	case ".*", "^.*$", ".+", "^.+$":
+		return true
+	}
+	if strings.HasPrefix(id, "/") {
+		return false
+	}
+	re, err := regexp.Compile(expr)
+	return err == nil && re.MatchString(id)
+}
+
+// analyzeAtlantisServerConfig flags server-side repo settings that let pull
+// requests change how plan runs, or apply without review. Atlantis merges
+// every entry matching a repo, so apply_requirements set on a broader entry
+// cover the narrower ones.
+func analyzeAtlantisServerConfig(file string, root *yaml.Node) []Finding {
+	const technique = "tfplanrecon_gitlab_atlantis_config"
+	var findings []Finding
+
+	_, repos := mappingValue(root, "repos")
+	if repos == nil || repos.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var required []string
+	for _, repo := range repos.Content {
+		_, id := mappingValue(repo, "id")
+		if _, reqs := mappingValue(repo, "apply_requirements"); id != nil && reqs != nil && len(reqs.Content) > 0 {
+			required = append(required, id.Value)
+		}
+	}
+
+	for _, repo := range repos.Content {
+		idKey, id := mappingValue(repo, "id")
+		if id == nil {
+			continue
+		}
+		line := idKey.Line
+
+		if key, custom := mappingValue(repo, "allow_custom_workflows"); custom != nil && custom.Value == "true" {
+			findings = append(findings, ciConfigFinding(technique, SeverityHigh,
+				"Atlantis Allows Custom Workflows",
+				fmt.Sprintf("Repos matching %s in %s may define their own workflows, so a pull request can change the commands Atlantis runs at plan time", id.Value, file),
+				file, key.Line, "repos."+id.Value))
+		}
+		if key, overrides := mappingValue(repo, "allowed_overrides"); overrides != nil && len(overrides.Content) > 0 {
+			severity := SeverityMedium
+			var names []string
+			for _, o := range overrides.Content {
+				names = append(names, o.Value)
+				if o.Value == "workflow" || o.Value == "apply_requirements" {
+					severity = SeverityHigh
+				}
+			}
+			findings = append(findings, ciConfigFinding(technique, severity,
+				"Atlantis Allows Repo Overrides",
+				fmt.Sprintf("Repos matching %s in %s may override %s from their atlantis.yaml", id.Value, file, strings.Join(names, ", ")),
+				file, key.Line, "repos."+id.Value))
+		}
+		reqsKey, reqs := mappingValue(repo, "apply_requirements")
+		switch {
+		case reqs != nil && len(reqs.Content) == 0:
+			findings = append(findings, ciConfigFinding(technique, SeverityMedium,
+				"Atlantis Apply Requirements Disabled",
+				fmt.Sprintf("Repos matching %s in %s set apply_requirements to an empty list, overriding any broader entry", id.Value, file),
+				file, reqsKey.Line, "repos."+id.Value))
+		case reqs == nil && !coveredBy(required, id.Value):
+			findings = append(findings, ciConfigFinding(technique, SeverityMedium,
+				"Atlantis Apply Requirements Missing",
+				fmt.Sprintf("Repos matching %s in %s have no apply_requirements, so a plan can be applied without an approved, mergeable pull request", id.Value, file),
+				file, line, "repos."+id.Value))
+		}
+	}
+	return findings
+}
+
+func coveredBy(patterns []string, id string) bool {
+	for _, pattern := range patterns {
+		if atlantisIDCovers(pattern, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeAtlantisRepoConfig flags repo-level atlantis.yaml projects without
+// apply requirements and custom workflows that add plan steps
+func analyzeAtlantisRepoConfig(file string, root *yaml.Node) []Finding {
+	const technique = "tfplanrecon_gitlab_atlantis_config"
+	var findings []Finding
+
+	if _, projects := mappingValue(root, "projects"); projects != nil && projects.Kind == yaml.SequenceNode {
+		for _, project := range projects.Content {
+			name := "(unnamed)"
+			if _, n := mappingValue(project, "name"); n != nil {
+				name = n.Value
+			} else if _, dir := mappingValue(project, "dir"); dir != nil {
+				name = dir.Value
+			}
+			if key, reqs := mappingValue(project, "apply_requirements"); reqs != nil && len(reqs.Content) == 0 {
+				findings = append(findings, ciConfigFinding(technique, SeverityMedium,
+					"Atlantis Apply Requirements Disabled",
+					fmt.Sprintf("Project %s in %s sets apply_requirements to an empty list, overriding any server requirement", name, file),
+					file, key.Line, "projects."+name))
+			}
+		}
+	}
+
+	if _, workflows := mappingValue(root, "workflows"); workflows != nil && workflows.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(workflows.Content); i += 2 {
+			name, body := workflows.Content[i], workflows.Content[i+1]
+			_, plan := mappingValue(body, "plan")
+			if !strings.Contains("\n"+nodeText(plan), "\nrun\n") {
+				continue
+			}
+			findings = append(findings, ciConfigFinding(technique, SeverityMedium,
+				"Atlantis Workflow Runs Custom Plan Steps",
+				fmt.Sprintf("Workflow %s in %s adds run steps to plan; they take effect wherever the server allows custom workflows", name.Value, file),
+				file, name.Line, "workflows."+name.Value))
+		}
+	}
+	return findings
+}
+
+// ciConfigFinding points a finding at a line of a CI configuration file
+func ciConfigFinding(technique string, severity Severity, title, message, file string, line int, address string) Finding {
+	f := newFinding(technique, severity, title, message)
+	f.Location = Location{File: file, Line: line, Address: address}
+	return f
+}
+
+// credentialVariables lists the credential-looking names in a variables mapping
+func credentialVariables(node *yaml.Node) []string {
+	_, vars := mappingValue(node, "variables")
+	if vars == nil || vars.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var names []string
+	for i := 0; i+1 < len(vars.Content); i += 2 {
+		key, value := vars.Content[i], vars.Content[i+1]
+		if credentialVarPattern.MatchString("$"+key.Value) || credentialVarPattern.MatchString(nodeText(value)) {
+			names = append(names, key.Value)
+		}
+	}
+	return names
+}
+
+// nodeText flattens every scalar under node, for pattern matching over
+// scripts and rules that may be strings or lists
+func nodeText(node *yaml.Node) string {
+	if node == nil {
+		return ""
+	}
+	if node.Kind == yaml.ScalarNode {
+		return node.Value + "\n"
+	}
+	var b strings.Builder
+	for _, child := range node.Content {
+		b.WriteString(nodeText(child))
+	}
+	return b.String()
+}
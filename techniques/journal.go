@@ -0,0 +1,263 @@
+package techniques
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"google.golang.org/api/cloudresourcemanager/v1"
+)
+
+// Tags placed on cloud artifacts a technique creates, so a rerun from a
+// runner without the journal still recognises its own work; GCP bindings
+// carry them in a condition description instead
+const (
+	tagEngagement = "tfplanrecon:engagement"
+	tagExecutedAt = "tfplanrecon:executed-at"
+)
+
+// JournalEntry records that a technique ran against a target during an
+// engagement, with the findings it produced
+type JournalEntry struct {
+	EngagementID string    `json:"engagement_id,omitempty"`
+	Technique    string    `json:"technique"`
+	Target       string    `json:"target"`
+	ExecutedAt   time.Time `json:"executed_at"`
+	Findings     []Finding `json:"findings,omitempty"`
+}
+
+func (e JournalEntry) key() string {
+	return strings.Join([]string{e.EngagementID, e.Technique, e.Target}, "\x00")
+}
+
+// Journal makes techniques with side effects or broad reads run once per
+// engagement and target. Data sources are read on every plan, apply and
+// refresh; later reads replay the journaled findings instead.
+type Journal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewJournal returns a journal stored at path
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+type journalFile struct {
+	Entries []JournalEntry `json:"entries"`
+}
+
+func (j *Journal) load() (journalFile, error) {
+	var journal journalFile
+	content, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return journal, fmt.Errorf("failed to read journal: %v", err)
+	}
+	if err := json.Unmarshal(content, &journal); err != nil {
+		return journal, fmt.Errorf("failed to parse journal: %v", err)
+	}
+	return journal, nil
+}
+
+// Lookup returns the earlier run of technique against target, if any
+func (j *Journal) Lookup(engagementID, technique, target string) (*JournalEntry, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	journal, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	want := JournalEntry{EngagementID: engagementID, Technique: technique, Target: target}.key()
+	for _, e := range journal.Entries {
+		if e.key() == want {
+			return &e, nil
+		}
+	}
+	return nil, nil
+}
+
+// Record adds or replaces the journal entry for entry's technique and target
+func (j *Journal) Record(entry JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	journal, err := j.load()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, e := range journal.Entries {
+		if e.key() == entry.key() {
+			journal.Entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		journal.Entries = append(journal.Entries, entry)
+	}
+	sort.Slice(journal.Entries, func(i, k int) bool {
+		return journal.Entries[i].ExecutedAt.Before(journal.Entries[k].ExecutedAt)
+	})
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create journal directory: %v", err)
+	}
+	return writeJSONFile(j.path, journal)
+}
+
+// alreadyExecuted reports whether technique already ran against target in
+// this engagement. If so it replays the earlier findings and returns the
+// diagnostics the technique should end with.
+func alreadyExecuted(m interface{}, technique, target string) (diag.Diagnostics, bool) {
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.Journal == nil {
+		return nil, false
+	}
+
+	entry, err := config.Journal.Lookup(config.engagementID(), technique, target)
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Failed to Read Technique Journal",
+			Detail:   fmt.Sprintf("Error reading %s, running %s against %s again: %v", config.Journal.path, technique, target, err),
+		}}, false
+	}
+	if entry == nil {
+		return nil, false
+	}
+
+	return append(executedDiagnostics(technique, target, entry.ExecutedAt, config.Journal.path),
+		recordFindings(m, entry.Findings...)...), true
+}
+
+// executedDiagnostics tells the operator why a technique did not run again
+func executedDiagnostics(technique, target string, at time.Time, source string) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Technique Already Executed",
+		Detail:   fmt.Sprintf("%s already ran against %s at %s (recorded in %s); remove that entry to run it again", technique, target, at.Format(time.RFC3339), source),
+	}}
+}
+
+// recordExecution records findings and journals the run so later plans in
+// the engagement do not repeat it
+func recordExecution(m interface{}, technique, target string, findings ...Finding) diag.Diagnostics {
+	diags := recordFindings(m, findings...)
+
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.Journal == nil {
+		return diags
+	}
+
+	err := config.Journal.Record(JournalEntry{
+		EngagementID: config.engagementID(),
+		Technique:    technique,
+		Target:       target,
+		ExecutedAt:   time.Now().UTC(),
+		Findings:     findings,
+	})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Failed to Update Technique Journal",
+			Detail:   fmt.Sprintf("Error writing %s; %s will run against %s again on the next plan: %v", config.Journal.path, technique, target, err),
+		})
+	}
+	return diags
+}
+
+// engagementTags marks an AWS artifact as created by this engagement
+func engagementTags(m interface{}) []*iam.Tag {
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.engagementID() == "" {
+		return []*iam.Tag{{Key: aws.String(tagExecutedAt), Value: aws.String(time.Now().UTC().Format(time.RFC3339))}}
+	}
+	return []*iam.Tag{
+		{Key: aws.String(tagEngagement), Value: aws.String(config.engagementID())},
+		{Key: aws.String(tagExecutedAt), Value: aws.String(time.Now().UTC().Format(time.RFC3339))},
+	}
+}
+
+// taggedByEngagement reports when the current engagement created a tagged
+// artifact. Artifacts from other engagements, or untagged ones, do not count.
+func taggedByEngagement(tags []*iam.Tag, m interface{}) (time.Time, bool) {
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.engagementID() == "" {
+		return time.Time{}, false
+	}
+
+	values := make(map[string]string)
+	for _, tag := range tags {
+		values[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	if values[tagEngagement] != config.engagementID() {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, values[tagExecutedAt])
+	return at, err == nil
+}
+
+// engagementCondition is the GCP counterpart of engagementTags. IAM bindings
+// carry no tags, so the marker is a condition that holds from now on and
+// records the engagement in its title and description.
+func engagementCondition(m interface{}) *cloudresourcemanager.Expr {
+	now := time.Now().UTC().Format(time.RFC3339)
+	expr := &cloudresourcemanager.Expr{
+		Title:       "tfplanrecon",
+		Description: fmt.Sprintf("%s=%s", tagExecutedAt, now),
+		Expression:  fmt.Sprintf("request.time >= timestamp(%q)", now),
+	}
+	if config, ok := m.(*ProviderConfig); ok && config.engagementID() != "" {
+		expr.Title = "tfplanrecon engagement " + config.engagementID()
+		expr.Description = fmt.Sprintf("%s=%s %s", tagEngagement, config.engagementID(), expr.Description)
+	}
+	return expr
+}
+
+// conditionedByEngagement reports when this engagement added a binding,
+// from the condition engagementCondition put on it
+func conditionedByEngagement(expr *cloudresourcemanager.Expr, m interface{}) (time.Time, bool) {
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.engagementID() == "" || expr == nil {
+		return time.Time{}, false
+	}
+
+	values := make(map[string]string)
+	for _, field := range strings.Fields(expr.Description) {
+		if key, value, ok := strings.Cut(field, "="); ok {
+			values[key] = value
+		}
+	}
+	if values[tagEngagement] != config.engagementID() {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, values[tagExecutedAt])
+	return at, err == nil
+}
+
+// awsTarget qualifies resource with the caller's account so journal entries
+// for different accounts do not collide
+func awsTarget(ctx context.Context, m interface{}, sess *session.Session, resource string) string {
+	identity, err := awsCallerIdentity(ctx, m, sess)
+	if err != nil {
+		return resource
+	}
+	return aws.StringValue(identity.Account) + "/" + resource
+}
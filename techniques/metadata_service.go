@@ -0,0 +1,352 @@
+package techniques
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	linkLocalEndpoint   = "http://169.254.169.254"
+	gcpMetadataEndpoint = "http://metadata.google.internal"
+)
+
+// MetadataService returns the schema for the instance metadata reachability check
+func MetadataService() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: metadataServiceRead,
+
+		Schema: map[string]*schema.Schema{
+			"timeout_seconds": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Default:     2,
+				Description: "How long to wait for each metadata endpoint before treating it as unreachable",
+			},
+		},
+	}
+}
+
+// metadataProbe is what one cloud's metadata service revealed to the plan process
+type metadataProbe struct {
+	Service   string
+	Reachable bool
+	Details   []string
+	Findings  []Finding
+}
+
+func (p metadataProbe) String() string {
+	if !p.Reachable {
+		return fmt.Sprintf("%s: unreachable", p.Service)
+	}
+	return fmt.Sprintf("%s: reachable; %s", p.Service, strings.Join(p.Details, "; "))
+}
+
+func metadataServiceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	timeout := time.Duration(d.Get("timeout_seconds").(int)) * time.Second
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Metadata Service Reachability",
+		Detail:   "Probing AWS, GCP and Azure instance metadata endpoints from the plan process; no credentials are requested",
+	})
+
+	client := metadataClient(timeout)
+	probes := []metadataProbe{
+		probeAwsImds(ctx, m, client),
+		probeGcpMetadata(ctx, client),
+		probeAzureImds(ctx, client),
+	}
+
+	var findings []Finding
+	var lines []string
+	reachable := 0
+	for _, p := range probes {
+		lines = append(lines, p.String())
+		findings = append(findings, p.Findings...)
+		if p.Reachable {
+			reachable++
+		}
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Metadata Services Probed",
+		Detail:   fmt.Sprintf("%d of %d metadata services reachable (container: %t):\n%s", reachable, len(probes), inContainer(), strings.Join(lines, "\n")),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("metadata-%d", reachable))
+	return diags
+}
+
+// metadataClient talks to link-local endpoints directly: a proxy would
+// answer from the wrong host, and redirects are never legitimate here
+func metadataClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:       nil,
+			DialContext: (&net.Dialer{Timeout: timeout}).DialContext,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// metadataRequest returns the status, response header and at most 64KiB of body
+func metadataRequest(ctx context.Context, client *http.Client, method, url string, headers map[string]string) (int, http.Header, string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, resp.Header, strings.TrimSpace(string(body)), err
+}
+
+// probeAwsImds checks whether IMDSv1 answers, whether an IMDSv2 token
+// reaches this process, and which role is attached. It lists the role name
+// under iam/security-credentials/ but never reads the credentials below it.
+func probeAwsImds(ctx context.Context, m interface{}, client *http.Client) metadataProbe {
+	probe := metadataProbe{Service: "AWS IMDS"}
+
+	v1Status, _, _, v1Err := metadataRequest(ctx, client, http.MethodGet, linkLocalEndpoint+"/latest/meta-data/", nil)
+	tokenStatus, _, token, tokenErr := metadataRequest(ctx, client, http.MethodPut, linkLocalEndpoint+"/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+
+	v1Allowed := v1Err == nil && v1Status == http.StatusOK
+	v2Token := tokenErr == nil && tokenStatus == http.StatusOK && token != ""
+	if !v1Allowed && !v2Token && !(v1Err == nil && v1Status == http.StatusUnauthorized) {
+		return probe
+	}
+	probe.Reachable = true
+
+	headers := map[string]string{}
+	switch {
+	case v2Token && !v1Allowed:
+		probe.Details = append(probe.Details, "IMDSv2 enforced")
+		headers["X-aws-ec2-metadata-token"] = token
+	case v2Token:
+		probe.Details = append(probe.Details, "IMDSv1 allowed")
+		headers["X-aws-ec2-metadata-token"] = token
+	case v1Allowed:
+		probe.Details = append(probe.Details, "IMDSv1 allowed; IMDSv2 token did not reach this process")
+	default:
+		// IMDSv2 is enforced but the PUT response was dropped on the way
+		// back, which is what a hop limit of 1 does to a containerized caller
+		probe.Details = append(probe.Details, "IMDSv2 enforced; token response did not reach this process (hop limit too low for this network namespace)")
+		return probe
+	}
+
+	var document struct {
+		InstanceID string `json:"instanceId"`
+		Region     string `json:"region"`
+		AccountID  string `json:"accountId"`
+	}
+	if status, _, body, err := metadataRequest(ctx, client, http.MethodGet, linkLocalEndpoint+"/latest/dynamic/instance-identity/document", headers); err == nil && status == http.StatusOK {
+		if err := json.Unmarshal([]byte(body), &document); err == nil {
+			probe.Details = append(probe.Details, fmt.Sprintf("instance %s in account %s (%s)", document.InstanceID, document.AccountID, document.Region))
+		}
+	}
+
+	hopLimit := imdsHopLimit(ctx, m, document.Region, document.InstanceID, v2Token)
+	probe.Details = append(probe.Details, "hop limit "+hopLimit)
+
+	role := ""
+	if status, _, body, err := metadataRequest(ctx, client, http.MethodGet, linkLocalEndpoint+"/latest/meta-data/iam/security-credentials/", headers); err == nil && status == http.StatusOK {
+		role = strings.SplitN(body, "\n", 2)[0]
+	}
+
+	target := document.InstanceID
+	if target == "" {
+		target = linkLocalEndpoint
+	}
+	if role != "" {
+		probe.Details = append(probe.Details, "instance role "+role)
+		probe.Findings = append(probe.Findings, newFinding("tfplanrecon_metadata_service", SeverityHigh,
+			"EC2 Instance Role Reachable from Plan Process",
+			fmt.Sprintf("The plan process can reach IMDS on %s and obtain credentials for instance role %s (%s, hop limit %s); any provider in the plan inherits that role", target, role, probe.Details[0], hopLimit),
+			target))
+	} else {
+		probe.Details = append(probe.Details, "no instance role")
+		probe.Findings = append(probe.Findings, newFinding("tfplanrecon_metadata_service", SeverityLow,
+			"EC2 Instance Metadata Reachable from Plan Process",
+			fmt.Sprintf("The plan process can reach IMDS on %s; no instance role is attached", target),
+			target))
+	}
+	if v1Allowed {
+		probe.Findings = append(probe.Findings, newFinding("tfplanrecon_metadata_service", SeverityMedium,
+			"IMDSv1 Enabled on Plan Runner",
+			fmt.Sprintf("IMDS on %s answers requests without a session token, so server-side request forgery from the plan reaches it", target),
+			target))
+	}
+	return probe
+}
+
+// imdsHopLimit reads the instance's metadata options with ec2:DescribeInstances
+// when the provider's AWS credentials come from somewhere other than IMDS;
+// otherwise, resolving them would request the instance role's credentials,
+// so the limit is inferred from whether a token reached this process
+func imdsHopLimit(ctx context.Context, m interface{}, region, instanceID string, tokenReceived bool) string {
+	inferred := "unknown"
+	if tokenReceived && inContainer() {
+		inferred = ">= 2 (token reached a containerized process)"
+	} else if tokenReceived {
+		inferred = ">= 1 (token reached the host network namespace)"
+	}
+
+	if region == "" || instanceID == "" || !awsCredentialsOutsideImds() {
+		return inferred
+	}
+
+	sess, err := newAwsSession(ctx, m, region)
+	if err != nil {
+		return inferred
+	}
+	result, err := ec2.New(sess).DescribeInstancesWithContext(ctx, &ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	})
+	if err != nil {
+		return fmt.Sprintf("%s; DescribeInstances failed (%s)", inferred, classifyError(err).Class)
+	}
+	for _, reservation := range result.Reservations {
+		for _, instance := range reservation.Instances {
+			if options := instance.MetadataOptions; options != nil {
+				return fmt.Sprintf("%d (http_tokens=%s)", aws.Int64Value(options.HttpPutResponseHopLimit), aws.StringValue(options.HttpTokens))
+			}
+		}
+	}
+	return inferred
+}
+
+// awsCredentialsOutsideImds reports whether the default credential chain
+// resolves before it would fall back to the instance role
+func awsCredentialsOutsideImds() bool {
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_PROFILE", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// probeGcpMetadata reads the default service account's email and scopes,
+// never its token
+func probeGcpMetadata(ctx context.Context, client *http.Client) metadataProbe {
+	probe := metadataProbe{Service: "GCP metadata"}
+	headers := map[string]string{"Metadata-Flavor": "Google"}
+
+	status, header, project, err := metadataRequest(ctx, client, http.MethodGet, gcpMetadataEndpoint+"/computeMetadata/v1/project/project-id", headers)
+	if err != nil || status != http.StatusOK || header.Get("Metadata-Flavor") != "Google" {
+		return probe
+	}
+	probe.Reachable = true
+	probe.Details = append(probe.Details, "project "+project)
+
+	email := ""
+	if status, _, body, err := metadataRequest(ctx, client, http.MethodGet, gcpMetadataEndpoint+"/computeMetadata/v1/instance/service-accounts/default/email", headers); err == nil && status == http.StatusOK {
+		email = body
+	}
+	if email == "" {
+		probe.Details = append(probe.Details, "no service account")
+		probe.Findings = append(probe.Findings, newFinding("tfplanrecon_metadata_service", SeverityLow,
+			"GCE Metadata Reachable from Plan Process",
+			fmt.Sprintf("The plan process can reach the metadata server of a GCE instance in project %s; no service account is attached", project),
+			project))
+		return probe
+	}
+
+	var scopes []string
+	if status, _, body, err := metadataRequest(ctx, client, http.MethodGet, gcpMetadataEndpoint+"/computeMetadata/v1/instance/service-accounts/default/scopes", headers); err == nil && status == http.StatusOK {
+		scopes = strings.Fields(body)
+	}
+	probe.Details = append(probe.Details, "service account "+email, "scopes "+listOrNone(scopes))
+
+	severity := SeverityHigh
+	if contains(scopes, "https://www.googleapis.com/auth/cloud-platform") {
+		severity = SeverityCritical
+	}
+	probe.Findings = append(probe.Findings, newFinding("tfplanrecon_metadata_service", severity,
+		"GCE Service Account Reachable from Plan Process",
+		fmt.Sprintf("The plan process can obtain tokens for service account %s in project %s with scopes %s; any provider in the plan inherits it", email, project, listOrNone(scopes)),
+		project))
+	return probe
+}
+
+// probeAzureImds reads the instance's compute metadata. Managed identities
+// are not enumerated because IMDS only reveals them through a token request.
+func probeAzureImds(ctx context.Context, client *http.Client) metadataProbe {
+	probe := metadataProbe{Service: "Azure IMDS"}
+
+	status, _, body, err := metadataRequest(ctx, client, http.MethodGet, linkLocalEndpoint+"/metadata/instance/compute?api-version=2021-02-01&format=json",
+		map[string]string{"Metadata": "true"})
+	if err != nil || status != http.StatusOK {
+		return probe
+	}
+
+	var compute struct {
+		Name           string `json:"name"`
+		SubscriptionID string `json:"subscriptionId"`
+		ResourceGroup  string `json:"resourceGroupName"`
+		Location       string `json:"location"`
+	}
+	if err := json.Unmarshal([]byte(body), &compute); err != nil || compute.SubscriptionID == "" {
+		return probe
+	}
+	probe.Reachable = true
+	probe.Details = append(probe.Details,
+		fmt.Sprintf("VM %s in subscription %s, resource group %s (%s)", compute.Name, compute.SubscriptionID, compute.ResourceGroup, compute.Location),
+		"managed identities not enumerated (requires a token request)")
+
+	probe.Findings = append(probe.Findings, newFinding("tfplanrecon_metadata_service", SeverityMedium,
+		"Azure Instance Metadata Reachable from Plan Process",
+		fmt.Sprintf("The plan process can reach IMDS on VM %s in subscription %s; any managed identity assigned to the VM is available to providers in the plan", compute.Name, compute.SubscriptionID),
+		compute.SubscriptionID))
+	return probe
+}
+
+// inContainer reports whether the plan process runs in a container, where a
+// hop limit of 1 would keep IMDSv2 tokens from reaching it
+func inContainer() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	cgroup, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod"} {
+		if strings.Contains(string(cgroup), runtime) {
+			return true
+		}
+	}
+	return false
+}
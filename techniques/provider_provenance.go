@@ -0,0 +1,497 @@
+package techniques
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// The CLI config is HCL 1, which allows quoted argument names; dev_overrides
+// keys are always quoted provider addresses
+var quotedArgumentPattern = regexp.MustCompile(`(?m)^[ \t]*"([^"]+)"[ \t]*=[ \t]*"([^"]*)"[ \t]*$`)
+
+const (
+	defaultRegistryHost = "registry.terraform.io"
+	selfProviderAddress = "registry.terraform.io/rileydakota/tfplanrecon"
+)
+
+// ProviderProvenance returns the schema for the provider installation provenance check
+func ProviderProvenance() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: providerProvenanceRead,
+
+		Schema: map[string]*schema.Schema{
+			"working_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     ".",
+				Description: "Terraform working directory holding .terraform.lock.hcl",
+			},
+		},
+	}
+}
+
+// dependencyLockFile is the subset of .terraform.lock.hcl the check reads
+type dependencyLockFile struct {
+	Providers []lockedProvider `hcl:"provider,block"`
+	Remain    hcl.Body         `hcl:",remain"`
+}
+
+type lockedProvider struct {
+	Address     string   `hcl:"address,label"`
+	Version     string   `hcl:"version,optional"`
+	Constraints string   `hcl:"constraints,optional"`
+	Hashes      []string `hcl:"hashes,optional"`
+}
+
+// cliConfig is the subset of the Terraform CLI config the check reads
+type cliConfig struct {
+	PluginCacheDir          string                 `hcl:"plugin_cache_dir,optional"`
+	PluginCacheMayBreakLock bool                   `hcl:"plugin_cache_may_break_dependency_lock_file,optional"`
+	ProviderInstallation    []providerInstallation `hcl:"provider_installation,block"`
+	Remain                  hcl.Body               `hcl:",remain"`
+
+	// quotedOverrides are the dev_overrides read before HCL 2 parsing
+	quotedOverrides map[string]string
+}
+
+type providerInstallation struct {
+	DevOverrides     []devOverrides  `hcl:"dev_overrides,block"`
+	FilesystemMirror []installMethod `hcl:"filesystem_mirror,block"`
+	NetworkMirror    []installMethod `hcl:"network_mirror,block"`
+	Direct           []installMethod `hcl:"direct,block"`
+}
+
+type installMethod struct {
+	Kind    string
+	Path    string   `hcl:"path,optional"`
+	URL     string   `hcl:"url,optional"`
+	Include []string `hcl:"include,optional"`
+	Exclude []string `hcl:"exclude,optional"`
+}
+
+type devOverrides struct {
+	Overrides hcl.Body `hcl:",remain"`
+}
+
+func (im installMethod) String() string {
+	source := im.Path + im.URL
+	if source == "" {
+		source = "origin registries"
+	}
+	return fmt.Sprintf("%s %s include=%s exclude=%s", im.Kind, source, listOrNone(im.Include), listOrNone(im.Exclude))
+}
+
+// permits reports whether the method would install the provider at address
+func (im installMethod) permits(address string) bool {
+	included := len(im.Include) == 0
+	for _, pattern := range im.Include {
+		if providerPatternMatches(pattern, address) {
+			included = true
+		}
+	}
+	for _, pattern := range im.Exclude {
+		if providerPatternMatches(pattern, address) {
+			return false
+		}
+	}
+	return included
+}
+
+// permitsAnyNamespace reports whether a direct installation method lets
+// terraform init fetch providers from namespaces nobody has vetted
+func (im installMethod) permitsAnyNamespace() bool {
+	if im.Kind != "direct" {
+		return false
+	}
+	for _, pattern := range im.Exclude {
+		if host, namespace, _ := splitProviderAddress(pattern); (host == "*" || host == defaultRegistryHost) && namespace == "*" {
+			return false
+		}
+	}
+	if len(im.Include) == 0 {
+		return true
+	}
+	for _, pattern := range im.Include {
+		if _, namespace, _ := splitProviderAddress(pattern); namespace == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func providerProvenanceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	workingDir := d.Get("working_dir").(string)
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Provider Installation Provenance",
+		Detail:   fmt.Sprintf("Reading the dependency lock file in %s, the CLI config and the plugin cache settings", workingDir),
+	})
+
+	var lines []string
+	var findings []Finding
+	report := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	// CLI config: where providers may come from
+	configPath, config, err := loadCLIConfig()
+	switch {
+	case err != nil:
+		report("CLI config %s: unparseable: %v", configPath, err)
+	case configPath == "":
+		report("CLI config: none")
+	default:
+		report("CLI config: %s", configPath)
+	}
+
+	methods, overrides := installationMethods(config)
+	if len(config.ProviderInstallation) == 0 {
+		report("Installation: implicit (local plugin directories, then the origin registry of each provider)")
+	}
+	for _, im := range methods {
+		report("Installation: %s", im)
+	}
+	for _, address := range sortedKeys(overrides) {
+		report("Installation: dev_overrides %s => %s", address, overrides[address])
+	}
+
+	var anyNamespace, permitsSelf []string
+	for _, im := range methods {
+		if im.permits(selfProviderAddress) {
+			permitsSelf = append(permitsSelf, im.Kind)
+		}
+		if im.permitsAnyNamespace() {
+			anyNamespace = append(anyNamespace, im.String())
+		}
+	}
+	report("Methods permitting %s: %s", selfProviderAddress, listOrNone(permitsSelf))
+	if len(anyNamespace) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityHigh,
+			"Providers Installable from Any Namespace",
+			fmt.Sprintf("terraform init on this runner installs providers from any registry namespace (%s), so any published provider, including %s, can run in the plan", strings.Join(anyNamespace, "; "), selfProviderAddress),
+			configPath))
+	}
+	if len(overrides) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityHigh,
+			"Provider Development Overrides Configured",
+			fmt.Sprintf("dev_overrides in %s load %d providers from local paths without version or checksum checks: %s", configPath, len(overrides), strings.Join(sortedKeys(overrides), ", ")),
+			configPath))
+	}
+
+	// Plugin cache: whether cached binaries bypass the lock file
+	cacheDir := os.Getenv("TF_PLUGIN_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = config.PluginCacheDir
+	}
+	mayBreakLock := config.PluginCacheMayBreakLock || os.Getenv("TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE") != ""
+	report("Plugin cache: %s (may break dependency lock file: %t)", valueOrNone(cacheDir), mayBreakLock)
+	if cacheDir != "" && mayBreakLock {
+		findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityMedium,
+			"Plugin Cache Allowed to Bypass Lock File",
+			fmt.Sprintf("Providers in the plugin cache %s are used even when their checksums are missing from the dependency lock file", cacheDir),
+			cacheDir))
+	}
+
+	// Lock file: whether hashes pinned what was installed
+	lockPath := filepath.Join(workingDir, ".terraform.lock.hcl")
+	lock, err := loadDependencyLockFile(lockPath)
+	switch {
+	case os.IsNotExist(err):
+		report("Lock file: none")
+		findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityMedium,
+			"No Dependency Lock File",
+			fmt.Sprintf("%s does not exist, so provider checksums are trusted on first use in every run", lockPath),
+			lockPath))
+	case err != nil:
+		report("Lock file %s: unparseable: %v", lockPath, err)
+	default:
+		committed := lockFileCommitted(ctx, lockPath)
+		readonly := lockFileReadonly()
+		report("Lock file: %s, %d providers, in version control: %s, init -lockfile=readonly: %t", lockPath, len(lock.Providers), committed, readonly)
+
+		var unhashed []string
+		self := false
+		for _, p := range lock.Providers {
+			report("  %s %s (%s) %d hashes", p.Address, p.Version, valueOrNone(p.Constraints), len(p.Hashes))
+			if len(p.Hashes) == 0 {
+				unhashed = append(unhashed, p.Address)
+			}
+			if p.Address == selfProviderAddress {
+				self = true
+			}
+		}
+		if len(unhashed) > 0 {
+			findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityMedium,
+				"Locked Providers Without Checksums",
+				fmt.Sprintf("%s pins %d providers without hashes, so any binary claiming those versions is accepted: %s", lockPath, len(unhashed), strings.Join(unhashed, ", ")),
+				lockPath))
+		}
+		if committed == "no" {
+			findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityMedium,
+				"Dependency Lock File Not in Version Control",
+				fmt.Sprintf("%s is generated by terraform init in the pipeline, so its hashes record whatever was installed rather than enforcing reviewed checksums", lockPath),
+				lockPath))
+		} else if self && committed == "yes" {
+			report("  %s is pinned in the committed lock file, so adding it passed review", selfProviderAddress)
+		}
+		if !readonly {
+			findings = append(findings, newFinding("tfplanrecon_provider_provenance", SeverityLow,
+				"Dependency Lock File Not Enforced Read-Only",
+				fmt.Sprintf("Neither TF_CLI_ARGS nor TF_CLI_ARGS_init sets -lockfile=readonly, so unless the pipeline passes it to terraform init directly, init can add providers and hashes to %s that were never reviewed", lockPath),
+				lockPath))
+		}
+	}
+
+	// This provider: how it reached the runner
+	report("This provider: %s", selfInstallation(cacheDir, overrides))
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Provider Installation Provenance",
+		Detail:   strings.Join(lines, "\n"),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("provenance-%d", len(findings)))
+	return diags
+}
+
+// loadCLIConfig reads TF_CLI_CONFIG_FILE or ~/.terraformrc; a missing file
+// yields the zero config, which is what Terraform applies too
+func loadCLIConfig() (string, cliConfig, error) {
+	var config cliConfig
+
+	path := os.Getenv("TF_CLI_CONFIG_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", config, nil
+		}
+		path = filepath.Join(home, ".terraformrc")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", config, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return path, config, err
+	}
+
+	// Read quoted arguments here and blank them so the rest parses as HCL 2
+	overrides := make(map[string]string)
+	if !strings.HasSuffix(path, ".json") {
+		content = quotedArgumentPattern.ReplaceAllFunc(content, func(line []byte) []byte {
+			match := quotedArgumentPattern.FindSubmatch(line)
+			overrides[string(match[1])] = string(match[2])
+			return nil
+		})
+	}
+
+	if err := decodeHCL(path, content, &config); err != nil {
+		return path, cliConfig{}, err
+	}
+	config.quotedOverrides = overrides
+	return path, config, nil
+}
+
+func loadDependencyLockFile(path string) (dependencyLockFile, error) {
+	var lock dependencyLockFile
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return lock, err
+	}
+	err = decodeHCL(path, content, &lock)
+	return lock, err
+}
+
+// decodeHCL parses native or JSON HCL syntax by extension
+func decodeHCL(path string, content []byte, target interface{}) error {
+	parser := hclparse.NewParser()
+	var file *hcl.File
+	var diags hcl.Diagnostics
+	if strings.HasSuffix(path, ".json") {
+		file, diags = parser.ParseJSON(content, path)
+	} else {
+		file, diags = parser.ParseHCL(content, path)
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+	if diags := gohcl.DecodeBody(file.Body, nil, target); diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
+
+// installationMethods flattens provider_installation in Terraform's order of
+// preference, or returns the implicit methods when there is none
+func installationMethods(config cliConfig) ([]installMethod, map[string]string) {
+	overrides := make(map[string]string)
+	var methods []installMethod
+
+	if len(config.ProviderInstallation) == 0 {
+		for _, dir := range implicitMirrorDirs() {
+			methods = append(methods, installMethod{Kind: "filesystem_mirror", Path: dir})
+		}
+		return append(methods, installMethod{Kind: "direct"}), overrides
+	}
+
+	for _, pi := range config.ProviderInstallation {
+		if len(pi.DevOverrides) > 0 {
+			for address, path := range config.quotedOverrides {
+				overrides[address] = path
+			}
+		}
+		for _, block := range pi.DevOverrides {
+			attrs, _ := block.Overrides.JustAttributes()
+			for name, attr := range attrs {
+				var path string
+				if diags := gohcl.DecodeExpression(attr.Expr, nil, &path); !diags.HasErrors() {
+					overrides[name] = path
+				}
+			}
+		}
+		for _, im := range pi.FilesystemMirror {
+			im.Kind = "filesystem_mirror"
+			methods = append(methods, im)
+		}
+		for _, im := range pi.NetworkMirror {
+			im.Kind = "network_mirror"
+			methods = append(methods, im)
+		}
+		for _, im := range pi.Direct {
+			im.Kind = "direct"
+			methods = append(methods, im)
+		}
+	}
+	return methods, overrides
+}
+
+// implicitMirrorDirs returns the local plugin directories Terraform searches
+// when the CLI config has no provider_installation block
+func implicitMirrorDirs() []string {
+	var dirs []string
+	candidates := []string{"terraform.d/plugins"}
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates,
+			filepath.Join(home, ".terraform.d", "plugins"),
+			filepath.Join(home, ".local", "share", "terraform", "plugins"))
+	}
+	candidates = append(candidates, "/usr/local/share/terraform/plugins", "/usr/share/terraform/plugins")
+
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// selfInstallation explains where the running provider binary came from
+func selfInstallation(cacheDir string, overrides map[string]string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Sprintf("unknown (%v)", err)
+	}
+	resolved, err := filepath.EvalSymlinks(exe)
+	if err != nil {
+		resolved = exe
+	}
+
+	if path, ok := overrides[selfProviderAddress]; ok && strings.HasPrefix(resolved, filepath.Clean(path)) {
+		return fmt.Sprintf("%s via dev_overrides", resolved)
+	}
+	if path, ok := overrides["rileydakota/tfplanrecon"]; ok && strings.HasPrefix(resolved, filepath.Clean(path)) {
+		return fmt.Sprintf("%s via dev_overrides", resolved)
+	}
+	if cacheDir != "" && strings.HasPrefix(resolved, filepath.Clean(cacheDir)) {
+		return fmt.Sprintf("%s from the plugin cache (linked from %s)", resolved, exe)
+	}
+	if strings.Contains(filepath.ToSlash(resolved), "/.terraform/providers/") {
+		return fmt.Sprintf("%s installed by terraform init into the working directory", resolved)
+	}
+	for _, dir := range implicitMirrorDirs() {
+		if abs, err := filepath.Abs(dir); err == nil && strings.HasPrefix(resolved, abs) {
+			return fmt.Sprintf("%s from the local plugin directory %s", resolved, dir)
+		}
+	}
+	return fmt.Sprintf("%s (origin unknown)", resolved)
+}
+
+// lockFileCommitted reports "yes", "no" or "unknown" for whether the lock
+// file is tracked in the enclosing repository, asking git itself so that
+// worktrees, submodules and every index format are handled
+func lockFileCommitted(ctx context.Context, lockPath string) string {
+	abs, err := filepath.Abs(lockPath)
+	if err != nil {
+		return "unknown"
+	}
+	err = exec.CommandContext(ctx, "git", "-C", filepath.Dir(abs), "ls-files", "--error-unmatch", "--", filepath.Base(abs)).Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return "yes"
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		// --error-unmatch exits 1 for untracked paths; not being in a
+		// repository at all exits 128
+		return "no"
+	}
+	return "unknown"
+}
+
+// lockFileReadonly reports whether TF_CLI_ARGS or TF_CLI_ARGS_init makes
+// terraform init refuse to change the lock file
+func lockFileReadonly() bool {
+	for _, name := range []string{"TF_CLI_ARGS", "TF_CLI_ARGS_init"} {
+		for _, arg := range strings.Fields(os.Getenv(name)) {
+			if arg == "-lockfile=readonly" || arg == "--lockfile=readonly" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitProviderAddress splits a provider address or include/exclude
+// pattern into hostname, namespace and type
+func splitProviderAddress(address string) (string, string, string) {
+	parts := strings.Split(address, "/")
+	switch len(parts) {
+	case 3:
+		return parts[0], parts[1], parts[2]
+	case 2:
+		return defaultRegistryHost, parts[0], parts[1]
+	case 1:
+		return defaultRegistryHost, "hashicorp", parts[0]
+	}
+	return "", "", ""
+}
+
+func providerPatternMatches(pattern, address string) bool {
+	ph, pn, pt := splitProviderAddress(pattern)
+	ah, an, at := splitProviderAddress(address)
+	match := func(p, v string) bool { return p == "*" || strings.EqualFold(p, v) }
+	return match(ph, ah) && match(pn, an) && match(pt, at)
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
@@ -0,0 +1,341 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// SideEffect classifies what a technique does to the client's infrastructure
+type SideEffect string
+
+const (
+	SideEffectReadOnly          SideEffect = "read-only"
+	SideEffectReversibleWrite   SideEffect = "reversible-write"
+	SideEffectIrreversibleWrite SideEffect = "irreversible-write"
+)
+
+// Technique is the registry entry for a data source, holding everything
+// reports, gating and docs need to know about it
+type Technique struct {
+	Name        string
+	RuleID      string
+	RuleName    string
+	Description string
+	MitreAttack []string
+	OwaspCicd   []string
+	SideEffect  SideEffect
+	Permissions []string
+	Remediation string
+	Resource    func() *schema.Resource
+}
+
+// registry holds every technique. It is filled in by init because the read
+// functions look up their own entries, which would otherwise form an
+// initialization cycle.
+var registry []Technique
+
+func init() {
+	registry = []Technique{
+		{
+			Name:        "tfplanrecon_env_var_exfil",
+			RuleID:      "TFPR001",
+			RuleName:    "EnvVarExfiltration",
+			Description: "Environment variables of the plan process can be sent to an external endpoint",
+			MitreAttack: []string{"T1552", "T1567"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-6"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"outbound HTTPS from the runner"},
+			Remediation: "Restrict egress from plan runners and keep long-lived credentials out of the plan environment; use short-lived, plan-scoped credentials.",
+			Resource:    EnvVarExfil,
+		},
+		{
+			Name:        "tfplanrecon_env_var_print",
+			RuleID:      "TFPR002",
+			RuleName:    "EnvVarDisclosure",
+			Description: "Environment variables of the plan process are readable by any provider",
+			MitreAttack: []string{"T1552"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-6"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{},
+			Remediation: "Keep secrets out of the plan environment and only install providers from an allowlisted set of namespaces.",
+			Resource:    EnvVarPrint,
+		},
+		{
+			Name:        "tfplanrecon_gcp_iam_binding",
+			RuleID:      "TFPR003",
+			RuleName:    "GcpIamBindingAtPlan",
+			Description: "The plan identity can modify the project IAM policy",
+			MitreAttack: []string{"T1098.003"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-2"},
+			SideEffect:  SideEffectReversibleWrite,
+			Permissions: []string{"resourcemanager.projects.getIamPolicy", "resourcemanager.projects.setIamPolicy"},
+			Remediation: "Give plan jobs a read-only identity without resourcemanager.projects.setIamPolicy and reserve write access for reviewed applies.",
+			Resource:    GcpIamBinding,
+		},
+		{
+			Name:        "tfplanrecon_aws_iam_role",
+			RuleID:      "TFPR004",
+			RuleName:    "AwsIamRoleAtPlan",
+			Description: "The plan identity can create IAM roles trusted by external principals",
+			MitreAttack: []string{"T1098.003", "T1136.003"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-2"},
+			SideEffect:  SideEffectReversibleWrite,
+			Permissions: []string{"iam:GetRole", "iam:CreateRole", "iam:TagRole"},
+			Remediation: "Give plan jobs a read-only role without iam:CreateRole and reserve write access for reviewed applies.",
+			Resource:    AwsIamRole,
+		},
+		{
+			Name:        "tfplanrecon_aws_secrets",
+			RuleID:      "TFPR005",
+			RuleName:    "AwsSecretsManagerRead",
+			Description: "The plan identity can read Secrets Manager secret values",
+			MitreAttack: []string{"T1555.006"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-6"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"secretsmanager:ListSecrets", "secretsmanager:GetSecretValue", "secretsmanager:GetResourcePolicy", "kms:DescribeKey"},
+			Remediation: "Remove secretsmanager:GetSecretValue from the plan role, scope secret resource policies to named in-account principals with conditions, and encrypt secrets with a customer managed key.",
+			Resource:    AwsSecretsExfil,
+		},
+		{
+			Name:        "tfplanrecon_aws_ssm",
+			RuleID:      "TFPR006",
+			RuleName:    "AwsSsmParameterRead",
+			Description: "The plan identity can read SSM parameters",
+			MitreAttack: []string{"T1555.006", "T1552"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-6"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"ssm:DescribeParameters", "iam:GetRole", "iam:SimulatePrincipalPolicy", "ssm:GetParametersByPath", "kms:Decrypt"},
+			Remediation: "Scope ssm:GetParameter* on the plan role to the paths it needs and deny kms:Decrypt on keys protecting SecureString parameters.",
+			Resource:    AwsSsmParameters,
+		},
+		{
+			Name:        "tfplanrecon_state_theft",
+			RuleID:      "TFPR007",
+			RuleName:    "StateFileRead",
+			Description: "The plan identity can read Terraform state files from remote backends",
+			MitreAttack: []string{"T1552.001", "T1530"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-6"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"s3:GetObject"},
+			Remediation: "Restrict state bucket access to the workspace that owns it and keep secrets out of state where possible.",
+			Resource:    StateFileTheft,
+		},
+		{
+			Name:        "tfplanrecon_github_workflows",
+			RuleID:      "TFPR008",
+			RuleName:    "GithubWorkflowPlanExposure",
+			Description: "GitHub Actions workflows run terraform plan for pull requests with cloud credentials or share identities with apply",
+			MitreAttack: []string{"T1195.002", "T1078.004"},
+			OwaspCicd:   []string{"CICD-SEC-4", "CICD-SEC-5"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"read access to the repository checkout"},
+			Remediation: "Do not run plan on pull_request_target or for fork pull requests, give PR plan jobs a read-only identity with no secrets, and use separate identities for plan and apply.",
+			Resource:    GithubWorkflows,
+		},
+		{
+			Name:        "tfplanrecon_gitlab_atlantis_config",
+			RuleID:      "TFPR009",
+			RuleName:    "GitlabAtlantisPlanExposure",
+			Description: "GitLab CI or Atlantis configuration runs plan for untrusted changes with credentials, or lets pull requests change plan behaviour",
+			MitreAttack: []string{"T1195.002", "T1078.004"},
+			OwaspCicd:   []string{"CICD-SEC-1", "CICD-SEC-4"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"read access to the repository checkout"},
+			Remediation: "Keep credentials out of merge request plan jobs, disable allow_custom_workflows and workflow overrides in Atlantis, and require approved and mergeable before apply.",
+			Resource:    GitlabAtlantisConfig,
+		},
+		{
+			Name:        "tfplanrecon_aws_oidc_trust",
+			RuleID:      "TFPR010",
+			RuleName:    "AwsOidcTrustTooBroad",
+			Description: "IAM roles trust CI OIDC providers without narrow sub and aud conditions, or one role serves both plan and apply",
+			MitreAttack: []string{"T1078.004", "T1550.001"},
+			OwaspCicd:   []string{"CICD-SEC-2", "CICD-SEC-5"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"iam:ListOpenIDConnectProviders", "iam:GetOpenIDConnectProvider", "iam:ListRoles"},
+			Remediation: "Pin each OIDC role's sub to one repository or workspace and a protected ref or run phase, require the expected aud, and give plan and apply separate roles.",
+			Resource:    AwsOidcTrust,
+		},
+		{
+			Name:        "tfplanrecon_gcp_workload_identity",
+			RuleID:      "TFPR011",
+			RuleName:    "GcpWorkloadIdentityTooBroad",
+			Description: "Workload identity providers or service account bindings admit any repository or branch of a CI issuer",
+			MitreAttack: []string{"T1078.004", "T1550.001"},
+			OwaspCicd:   []string{"CICD-SEC-2", "CICD-SEC-5"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"iam.workloadIdentityPools.list", "iam.workloadIdentityPoolProviders.list", "iam.serviceAccounts.list", "iam.serviceAccounts.getIamPolicy"},
+			Remediation: "Set an attribute_condition that pins the repository and a protected ref, and grant roles/iam.workloadIdentityUser to a single attribute.repository principal set rather than the whole pool.",
+			Resource:    GcpWorkloadIdentity,
+		},
+		{
+			Name:        "tfplanrecon_metadata_service",
+			RuleID:      "TFPR012",
+			RuleName:    "MetadataServiceReachable",
+			Description: "Instance metadata endpoints, and the identity attached to the runner, are reachable from the plan process",
+			MitreAttack: []string{"T1552.005", "T1078.004"},
+			OwaspCicd:   []string{"CICD-SEC-5", "CICD-SEC-7"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"network access to 169.254.169.254 and metadata.google.internal", "ec2:DescribeInstances (optional, for the hop limit)"},
+			Remediation: "Block metadata endpoints from plan jobs, enforce IMDSv2 with a hop limit of 1 on containerized runners, and give runner instances no role beyond what the runner itself needs.",
+			Resource:    MetadataService,
+		},
+		{
+			Name:        "tfplanrecon_credential_files",
+			RuleID:      "TFPR013",
+			RuleName:    "CredentialFilesOnRunner",
+			Description: "Long-lived credential files on the runner filesystem are readable by the plan process",
+			MitreAttack: []string{"T1552.001", "T1552.004"},
+			OwaspCicd:   []string{"CICD-SEC-6", "CICD-SEC-7"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"read access to the runner user's home directory"},
+			Remediation: "Run plan jobs on ephemeral runners or in a fresh home directory, and replace stored cloud, registry and SSH credentials with short-lived credentials issued per job.",
+			Resource:    CredentialFiles,
+		},
+		{
+			Name:        "tfplanrecon_shared_runner",
+			RuleID:      "TFPR014",
+			RuleName:    "SharedRunnerCrossTenant",
+			Description: "Checkouts, Terraform working directories, plan files and provider caches of other workloads are readable on the runner",
+			MitreAttack: []string{"T1083", "T1552.001"},
+			OwaspCicd:   []string{"CICD-SEC-5", "CICD-SEC-7"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"read access to the runner's work and temp directories"},
+			Remediation: "Run plan jobs on ephemeral, single-repository runners or isolate each job in its own container and user, and do not share provider plugin caches between teams.",
+			Resource:    SharedRunner,
+		},
+		{
+			Name:        "tfplanrecon_provider_provenance",
+			RuleID:      "TFPR015",
+			RuleName:    "ProviderInstallationUnrestricted",
+			Description: "Terraform installs providers from any namespace, or without checksums enforced by a reviewed lock file",
+			MitreAttack: []string{"T1195.001", "T1554"},
+			OwaspCicd:   []string{"CICD-SEC-3", "CICD-SEC-9"},
+			SideEffect:  SideEffectReadOnly,
+			Permissions: []string{"read access to the working directory and the Terraform CLI config"},
+			Remediation: "Set provider_installation so only an internal mirror or an explicit allowlist of namespaces is used, commit .terraform.lock.hcl and run terraform init with -lockfile=readonly in pipelines.",
+			Resource:    ProviderProvenance,
+		},
+	}
+}
+
+// Registry returns every registered technique, ordered by name
+func Registry() []Technique {
+	out := make([]Technique, len(registry))
+	copy(out, registry)
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// LookupTechnique returns the registry entry for a data source type
+func LookupTechnique(name string) (Technique, bool) {
+	for _, t := range registry {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Technique{}, false
+}
+
+// DataSources builds the provider's DataSourcesMap from the registry
+func DataSources() map[string]*schema.Resource {
+	dataSources := map[string]*schema.Resource{
+		"tfplanrecon_techniques": Techniques(),
+		"tfplanrecon_self_check": SelfCheck(),
+	}
+	for _, t := range registry {
+		dataSources[t.Name] = gate(t)
+	}
+	return dataSources
+}
+
+// gate wraps a technique's read function so it only runs when the provider
+// configuration allows it
+func gate(t Technique) *schema.Resource {
+	resource := t.Resource()
+	read := resource.ReadContext
+
+	resource.ReadContext = func(ctx context.Context, d *schema.ResourceData, m interface{}) (diags diag.Diagnostics) {
+		if config, ok := m.(*ProviderConfig); ok {
+			if config.Findings != nil {
+				config.Findings.Start()
+				defer func() {
+					if summary := config.Findings.Finish(); summary != nil {
+						diags = append(diags, *summary)
+					}
+				}()
+			}
+
+			if err := config.authorize(t); err != nil {
+				return diag.Diagnostics{{
+					Severity: diag.Error,
+					Summary:  "TFPLANRECON Technique Refused",
+					Detail:   err.Error(),
+				}}
+			}
+			ctx = withCallBudget(ctx, t.Name, config.CallBudget, config.Limiter)
+
+			if config.TechniqueTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, config.TechniqueTimeout)
+				defer cancel()
+			}
+		}
+		return read(ctx, d, m)
+	}
+	return resource
+}
+
+// Techniques returns the schema for the technique registry data source
+func Techniques() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: techniquesRead,
+
+		Schema: map[string]*schema.Schema{
+			"techniques": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Every technique the provider implements, with its mappings and remediation",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name":         {Type: schema.TypeString, Computed: true},
+						"rule_id":      {Type: schema.TypeString, Computed: true},
+						"description":  {Type: schema.TypeString, Computed: true},
+						"mitre_attack": {Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeString}},
+						"owasp_cicd":   {Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeString}},
+						"side_effect":  {Type: schema.TypeString, Computed: true},
+						"permissions":  {Type: schema.TypeList, Computed: true, Elem: &schema.Schema{Type: schema.TypeString}},
+						"remediation":  {Type: schema.TypeString, Computed: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+func techniquesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var techniques []interface{}
+	for _, t := range Registry() {
+		techniques = append(techniques, map[string]interface{}{
+			"name":         t.Name,
+			"rule_id":      t.RuleID,
+			"description":  t.Description,
+			"mitre_attack": t.MitreAttack,
+			"owasp_cicd":   t.OwaspCicd,
+			"side_effect":  string(t.SideEffect),
+			"permissions":  t.Permissions,
+			"remediation":  t.Remediation,
+		})
+	}
+
+	if err := d.Set("techniques", techniques); err != nil {
+		return diag.FromErr(fmt.Errorf("error setting techniques: %v", err))
+	}
+
+	d.SetId("techniques")
+	return nil
+}
@@ -0,0 +1,261 @@
+package techniques
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "tfplanrecon"
+	toolURI      = "https://github.com/rileydakota/terraform-provider-tfplanrecon"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name"`
+	ShortDescription sarifText         `json:"shortDescription"`
+	Help             sarifText         `json:"help"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifText         `json:"message"`
+	Locations  []sarifLocation   `json:"locations,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// jsonReport is the plain JSON export, for trackers that do not speak SARIF
+type jsonReport struct {
+	Tool        string    `json:"tool"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Findings    []Finding `json:"findings"`
+}
+
+// writeReports writes the SARIF and JSON reports for findings into dir
+func writeReports(dir string, findings []Finding) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create report directory: %v", err)
+	}
+
+	if err := writeJSONFile(filepath.Join(dir, "tfplanrecon.sarif"), buildSarif(findings)); err != nil {
+		return err
+	}
+
+	return writeJSONFile(filepath.Join(dir, "tfplanrecon.json"), jsonReport{
+		Tool:        toolName,
+		GeneratedAt: time.Now().UTC(),
+		Findings:    findings,
+	})
+}
+
+func buildSarif(findings []Finding) sarifLog {
+	// A rule is ranked by its worst finding; control findings recorded
+	// before an exposure must not lower it
+	rules := make(map[string]*sarifRule)
+	worst := make(map[string]Severity)
+	var ruleList []*sarifRule
+	results := make([]sarifResult, 0, len(findings))
+	root := checkoutRoot()
+
+	for _, f := range findings {
+		rule, ok := rules[f.RuleID]
+		if !ok {
+			t, _ := LookupTechnique(f.Technique)
+			rule = &sarifRule{
+				ID:               f.RuleID,
+				Name:             t.RuleName,
+				ShortDescription: sarifText{Text: t.Description},
+				Help:             sarifText{Text: t.Remediation},
+				Properties:       map[string]string{},
+			}
+			rules[f.RuleID] = rule
+			ruleList = append(ruleList, rule)
+		}
+		if !ok || severityRank(f.Severity) < severityRank(worst[f.RuleID]) {
+			worst[f.RuleID] = f.Severity
+			rule.Properties["security-severity"] = securitySeverity(f.Severity)
+		}
+
+		result := sarifResult{
+			RuleID:  f.RuleID,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifText{Text: fmt.Sprintf("%s: %s", f.Title, f.Message)},
+			Properties: map[string]string{
+				"severity":          string(f.Severity),
+				"security-severity": securitySeverity(f.Severity),
+				"outcome":           string(f.Outcome),
+				"technique":         f.Technique,
+				"remediation":       f.Remediation,
+			},
+		}
+		if f.Context != nil {
+			result.Properties["execution-context"] = f.Context.String()
+		}
+		if f.Location.File != "" || f.Location.Address != "" {
+			loc := sarifLocation{}
+			if f.Location.File != "" {
+				loc.PhysicalLocation = &sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: repoRelative(root, f.Location.File), URIBaseID: "%SRCROOT%"},
+				}
+				if f.Location.Line > 0 {
+					loc.PhysicalLocation.Region = &sarifRegion{StartLine: f.Location.Line}
+				}
+			}
+			if f.Location.Address != "" {
+				loc.LogicalLocations = []sarifLogicalLocation{{
+					FullyQualifiedName: f.Location.Address,
+					Kind:               "resource",
+				}}
+			}
+			result.Locations = []sarifLocation{loc}
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(ruleList, func(i, j int) bool { return ruleList[i].ID < ruleList[j].ID })
+	sorted := make([]sarifRule, 0, len(ruleList))
+	for _, rule := range ruleList {
+		sorted = append(sorted, *rule)
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           toolName,
+				InformationURI: toolURI,
+				Rules:          sorted,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// repoRelative rewrites a path relative to the working directory so it is
+// relative to the repository root, which code scanning resolves %SRCROOT% to
+func repoRelative(root, file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// severityRank orders severities from critical (0) to info
+func severityRank(s Severity) int {
+	for i, severity := range []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo} {
+		if s == severity {
+			return i
+		}
+	}
+	return 5
+}
+
+// sarifLevel maps a severity onto the three SARIF result levels
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity is the numeric score GitHub code scanning uses to rank alerts
+func securitySeverity(s Severity) string {
+	switch s {
+	case SeverityCritical:
+		return "9.5"
+	case SeverityHigh:
+		return "8.0"
+	case SeverityMedium:
+		return "5.5"
+	case SeverityLow:
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// writeJSONFile writes v to path through a temporary file so readers never
+// see a half-written report
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %s", filepath.Base(path), err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
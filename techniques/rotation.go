@@ -0,0 +1,168 @@
+package techniques
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/rileydakota/tf-plan-recon/detector"
+)
+
+// Credential sources recorded in the rotation manifest
+const (
+	SourceSecretsManager = "secretsmanager"
+	SourceSSM            = "ssm"
+	SourceEnv            = "env"
+	SourceState          = "state"
+)
+
+// RotationEntry is one credential the client should rotate. It identifies
+// where the credential lives; values are never recorded.
+type RotationEntry struct {
+	Source        string    `json:"source"`
+	Identifier    string    `json:"identifier"`
+	AttributePath string    `json:"attribute_path,omitempty"`
+	Pipeline      string    `json:"pipeline,omitempty"`
+	Kinds         []string  `json:"kinds,omitempty"`
+	Technique     string    `json:"technique"`
+	FirstObserved time.Time `json:"first_observed"`
+	LastObserved  time.Time `json:"last_observed"`
+}
+
+func (e RotationEntry) key() string {
+	return strings.Join([]string{e.Source, e.Identifier, e.AttributePath, e.Pipeline}, "\x00")
+}
+
+// RotationManifest accumulates every credential the engagement touched.
+// The file is merged on every write, so it spans all plans of an engagement.
+type RotationManifest struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewRotationManifest returns a manifest stored at path
+func NewRotationManifest(path string) *RotationManifest {
+	return &RotationManifest{path: path}
+}
+
+type rotationFile struct {
+	EngagementID string          `json:"engagement_id,omitempty"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Entries      []RotationEntry `json:"entries"`
+}
+
+// Observe merges entries into the manifest on disk
+func (r *RotationManifest) Observe(engagementID string, entries ...RotationEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var manifest rotationFile
+	if content, err := os.ReadFile(r.path); err == nil {
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			return fmt.Errorf("failed to parse existing rotation manifest: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read rotation manifest: %v", err)
+	}
+
+	index := make(map[string]int, len(manifest.Entries))
+	for i, e := range manifest.Entries {
+		index[e.key()] = i
+	}
+
+	for _, e := range entries {
+		if i, ok := index[e.key()]; ok {
+			existing := &manifest.Entries[i]
+			existing.LastObserved = e.LastObserved
+			existing.Kinds = mergeKinds(existing.Kinds, e.Kinds)
+			continue
+		}
+		index[e.key()] = len(manifest.Entries)
+		manifest.Entries = append(manifest.Entries, e)
+	}
+
+	sort.Slice(manifest.Entries, func(i, j int) bool {
+		return manifest.Entries[i].key() < manifest.Entries[j].key()
+	})
+	if engagementID != "" {
+		manifest.EngagementID = engagementID
+	}
+	manifest.UpdatedAt = time.Now().UTC()
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create rotation manifest directory: %v", err)
+	}
+	return writeJSONFile(r.path, manifest)
+}
+
+func mergeKinds(a, b []string) []string {
+	for _, kind := range b {
+		if !contains(a, kind) {
+			a = append(a, kind)
+		}
+	}
+	sort.Strings(a)
+	return a
+}
+
+// newRotationEntry builds an entry observed now by technique
+func newRotationEntry(technique, source, identifier string, kinds []detector.Kind) RotationEntry {
+	now := time.Now().UTC()
+	entry := RotationEntry{
+		Source:        source,
+		Identifier:    identifier,
+		Technique:     technique,
+		FirstObserved: now,
+		LastObserved:  now,
+	}
+	for _, kind := range kinds {
+		entry.Kinds = mergeKinds(entry.Kinds, []string{string(kind)})
+	}
+	return entry
+}
+
+// observeCredentials adds entries to the provider's rotation manifest. A
+// failed write is surfaced as a warning since it should not abort the technique.
+func observeCredentials(m interface{}, entries ...RotationEntry) diag.Diagnostics {
+	config, ok := m.(*ProviderConfig)
+	if !ok || config.Rotation == nil || len(entries) == 0 {
+		return nil
+	}
+
+	if err := config.Rotation.Observe(config.engagementID(), entries...); err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Failed to Update Rotation Manifest",
+			Detail:   fmt.Sprintf("Error writing rotation manifest to %s: %v", config.Rotation.path, err),
+		}}
+	}
+	return nil
+}
+
+// envRotationEntries lists the credential-bearing environment variables,
+// tagged with the pipeline they were observed in
+func envRotationEntries(technique string, envVars map[string]string) []RotationEntry {
+	var pipelines []string
+	for _, identity := range DetectPipelineIdentities() {
+		pipelines = append(pipelines, identity.String())
+	}
+	pipeline := strings.Join(pipelines, ",")
+
+	var entries []RotationEntry
+	for name, value := range envVars {
+		kinds := detector.Detect(name, value)
+		if len(kinds) == 0 {
+			continue
+		}
+		entry := newRotationEntry(technique, SourceEnv, name, kinds)
+		entry.Pipeline = pipeline
+		entries = append(entries, entry)
+	}
+	return entries
+}
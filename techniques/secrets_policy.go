@@ -0,0 +1,268 @@
+package techniques
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+	secretsModePolicy = "policy"
+	secretsModeRead   = "read"
+)
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// secretMetadata is what ListSecrets reveals without reading a value
+type secretMetadata struct {
+	Name  string
+	ARN   string
+	KeyID string
+}
+
+// secretsMode validates the mode attribute; policy is opt-in and read keeps
+// the technique's original behaviour
+func secretsMode(mode string) (string, error) {
+	switch mode {
+	case secretsModePolicy, secretsModeRead:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("mode must be %q or %q, got %q", secretsModePolicy, secretsModeRead, mode)
+	}
+}
+
+// assessSecretPolicies lists secrets and explains who else can read them:
+// resource policies granting wildcard or cross-account principals, grants
+// without conditions, and secrets under the AWS managed key, whose key
+// policy cannot narrow who decrypts. No secret value is read.
+func assessSecretPolicies(ctx context.Context, m interface{}, sess *session.Session, region, nameFilter string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	client := secretsmanager.New(sess)
+	limit := maxTargets(m)
+
+	input := &secretsmanager.ListSecretsInput{}
+	if nameFilter != "" {
+		input.Filters = []*secretsmanager.Filter{
+			{
+				Key:    aws.String("name"),
+				Values: []*string{aws.String(nameFilter)},
+			},
+		}
+	}
+
+	var secrets []secretMetadata
+	err := client.ListSecretsPagesWithContext(ctx, input, func(page *secretsmanager.ListSecretsOutput, lastPage bool) bool {
+		for _, secret := range page.SecretList {
+			if limit > 0 && len(secrets) >= limit {
+				return false
+			}
+			secrets = append(secrets, secretMetadata{
+				Name:  aws.StringValue(secret.Name),
+				ARN:   aws.StringValue(secret.ARN),
+				KeyID: aws.StringValue(secret.KmsKeyId),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		ce := classifyError(err)
+		switch {
+		case ce.Denied():
+			return append(diags, recordControl(m, "tfplanrecon_aws_secrets", "secretsmanager:ListSecrets", region, ce, region, nameFilter)...)
+		case ce.Class == ErrorCanceled:
+			diags = append(diags, partialResults(ctx, "secrets listed", len(secrets)))
+		default:
+			return diag.FromErr(fmt.Errorf("failed to list secrets (%s): %v", ce.Class, err))
+		}
+	}
+
+	if len(secrets) == 0 {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No Secrets Found",
+			Detail:   fmt.Sprintf("No secrets visible in region %s", region),
+		})
+	}
+
+	keys := make(map[string]bool)
+	for _, s := range secrets {
+		if !awsManagedKeyID(s.KeyID) {
+			keys[s.KeyID] = true
+		}
+	}
+	if err := project(ctx, len(secrets)+len(keys), "GetResourcePolicy and DescribeKey calls"); err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Secret Policy Assessment Skipped: API Call Budget",
+			Detail:   fmt.Sprintf("Found %d secrets but stopped before reading their policies: %v", len(secrets), err),
+		})
+	}
+
+	account := ""
+	if identity, err := awsCallerIdentity(ctx, m, sess); err == nil {
+		account = aws.StringValue(identity.Account)
+	}
+	managers := describeKeyManagers(ctx, kms.New(sess), sortedKeys(keys))
+
+	var findings []Finding
+	var lines []string
+	var awsManaged []string
+	for _, s := range secrets {
+		manager := kms.KeyManagerTypeAws
+		if !awsManagedKeyID(s.KeyID) {
+			manager = managers[s.KeyID]
+		}
+		if manager == kms.KeyManagerTypeAws {
+			awsManaged = append(awsManaged, s.Name)
+		}
+
+		result, err := client.GetResourcePolicyWithContext(ctx, &secretsmanager.GetResourcePolicyInput{
+			SecretId: aws.String(s.ARN),
+		})
+		if err != nil {
+			ce := classifyError(err)
+			if ce.Class == ErrorCanceled {
+				diags = append(diags, partialResults(ctx, "secret policies read", len(lines)))
+				break
+			}
+			if ce.Denied() {
+				f := controlFinding("tfplanrecon_aws_secrets", "secretsmanager:GetResourcePolicy", s.Name, ce, region, nameFilter)
+				diags = append(diags, controlDiagnostic("secretsmanager:GetResourcePolicy", f))
+				findings = append(findings, f)
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s key=%s policy unreadable (%s)", s.Name, manager, ce.Class))
+			continue
+		}
+
+		document := aws.StringValue(result.ResourcePolicy)
+		if document == "" {
+			lines = append(lines, fmt.Sprintf("%s key=%s policy=none (access governed by identity policies)", s.Name, manager))
+			continue
+		}
+		issues, err := auditSecretPolicy(s, document, account, region, nameFilter)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s key=%s policy unparseable: %v", s.Name, manager, err))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s key=%s policy issues=%d", s.Name, manager, len(issues)))
+		findings = append(findings, issues...)
+	}
+
+	if len(awsManaged) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_aws_secrets", SeverityLow,
+			"Secrets Encrypted with the AWS Managed Key",
+			fmt.Sprintf("%d secrets in region %s use aws/secretsmanager, whose key policy lets any principal in the account with secret read access decrypt them and cannot be shared cross-account: %s",
+				len(awsManaged), region, strings.Join(awsManaged, ", ")),
+			region, nameFilter))
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "AWS Secrets Manager Policies Assessed",
+		Detail:   fmt.Sprintf("Assessed %d secrets in region %s; no values were read:\n%s", len(secrets), region, strings.Join(lines, "\n")),
+	})
+	return append(diags, recordFindings(m, findings...)...)
+}
+
+// describeKeyManagers reports whether each customer-specified key is
+// AWS or customer managed, or "unknown" when DescribeKey fails
+func describeKeyManagers(ctx context.Context, client *kms.KMS, keyIDs []string) map[string]string {
+	managers := make(map[string]string)
+	for _, keyID := range keyIDs {
+		result, err := client.DescribeKeyWithContext(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+		if err != nil {
+			managers[keyID] = "unknown"
+			continue
+		}
+		managers[keyID] = aws.StringValue(result.KeyMetadata.KeyManager)
+	}
+	return managers
+}
+
+// awsManagedKeyID reports whether a secret's KmsKeyId is the default key;
+// ListSecrets leaves it empty or names the aws/secretsmanager alias
+func awsManagedKeyID(keyID string) bool {
+	return keyID == "" || strings.HasPrefix(keyID, "alias/aws/") || strings.Contains(keyID, ":alias/aws/")
+}
+
+// auditSecretPolicy flags statements that let principals outside the
+// caller's account, or anyone at all, read the secret
+func auditSecretPolicy(secret secretMetadata, document, account, region, nameFilter string) ([]Finding, error) {
+	var policy struct {
+		Statement policyStatements `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(document), &policy); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, stmt := range policy.Statement {
+		if stmt.Effect != "Allow" || !allowsAction(stmt.Action, "secretsmanager:GetSecretValue") {
+			continue
+		}
+		conditioned := len(stmt.Condition) > 0
+
+		for _, principal := range stmt.Principal["AWS"] {
+			principalAccount := policyPrincipalAccount(principal)
+			switch {
+			case principal == "*" && !conditioned:
+				findings = append(findings, newFinding("tfplanrecon_aws_secrets", SeverityCritical,
+					"Secret Readable by Any AWS Principal",
+					fmt.Sprintf("Resource policy on %s grants secretsmanager:GetSecretValue to \"*\" without conditions", secret.ARN),
+					region, nameFilter))
+			case principal == "*":
+				findings = append(findings, newFinding("tfplanrecon_aws_secrets", SeverityMedium,
+					"Secret Readable by Any AWS Principal Matching Conditions",
+					fmt.Sprintf("Resource policy on %s grants secretsmanager:GetSecretValue to \"*\", limited only by conditions on %s", secret.ARN, strings.Join(sortedKeys(stmt.Condition), ", ")),
+					region, nameFilter))
+			case principalAccount != "" && account != "" && principalAccount != account:
+				severity := SeverityHigh
+				if conditioned {
+					severity = SeverityMedium
+				}
+				findings = append(findings, newFinding("tfplanrecon_aws_secrets", severity,
+					"Secret Readable Cross-Account",
+					fmt.Sprintf("Resource policy on %s grants secretsmanager:GetSecretValue to %s in account %s (conditions: %t)", secret.ARN, principal, principalAccount, conditioned),
+					region, nameFilter))
+			case !conditioned:
+				findings = append(findings, newFinding("tfplanrecon_aws_secrets", SeverityLow,
+					"Secret Resource Policy Grant Without Conditions",
+					fmt.Sprintf("Resource policy on %s grants secretsmanager:GetSecretValue to %s with no source, VPC or tag conditions", secret.ARN, principal),
+					region, nameFilter))
+			}
+		}
+	}
+	return findings, nil
+}
+
+// allowsAction reports whether any of a statement's action patterns match
+// action; IAM action names are case-insensitive
+func allowsAction(patterns []string, action string) bool {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(pattern)
+	}
+	return iamLikeAny(lowered, []string{strings.ToLower(action)})
+}
+
+// policyPrincipalAccount returns the account of an AWS principal given as
+// an ARN or a bare account ID
+func policyPrincipalAccount(principal string) string {
+	if accountIDPattern.MatchString(principal) {
+		return principal
+	}
+	parts := strings.Split(principal, ":")
+	if len(parts) >= 5 && parts[0] == "arn" {
+		return parts[4]
+	}
+	return ""
+}
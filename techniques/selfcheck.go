@@ -0,0 +1,381 @@
+package techniques
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/oauth2/google"
+)
+
+var webhookURLPattern = regexp.MustCompile(`webhook_url\s*=\s*"([^"]+)"`)
+
+// SelfCheck returns the schema for the self check data source. It reports
+// how the provider is armed and scoped without running any technique.
+func SelfCheck() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: selfCheckRead,
+
+		Schema: map[string]*schema.Schema{
+			"version": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Provider build version",
+			},
+			"engagement_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the loaded engagement config, empty if none",
+			},
+			"engagement_time_remaining": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Time left in the engagement window",
+			},
+			"armed": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether techniques may run right now",
+			},
+			"arming_state": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Why the provider is or is not armed",
+			},
+			"pipelines": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Pipeline identities detected in the environment",
+			},
+			"enabled_techniques": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Techniques that would run under the current gating settings",
+			},
+			"disabled_techniques": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Techniques that would be refused, with the reason",
+			},
+			"aws_caller_identity": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ARN the AWS credentials resolve to",
+			},
+			"gcp_principal": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Principal the GCP application default credentials belong to",
+			},
+			"sinks": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Report, manifest, journal and webhook destinations and whether they are reachable",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name":      {Type: schema.TypeString, Computed: true},
+						"target":    {Type: schema.TypeString, Computed: true},
+						"reachable": {Type: schema.TypeBool, Computed: true},
+						"detail":    {Type: schema.TypeString, Computed: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+// selfCheckSink is one destination the provider writes or sends results to
+type selfCheckSink struct {
+	Name      string
+	Target    string
+	Reachable bool
+	Detail    string
+}
+
+func selfCheckRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config, ok := m.(*ProviderConfig)
+	if !ok {
+		return diag.FromErr(fmt.Errorf("provider is not configured"))
+	}
+
+	var lines []string
+	report := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	report("Version: %s", config.Version)
+
+	armed, state, remaining := config.armingState(time.Now())
+	report("Armed: %t (%s)", armed, state)
+	if config.Engagement != nil {
+		report("Engagement: %s, %s remaining", config.Engagement.ID, remaining)
+	}
+
+	var pipelines []string
+	for _, identity := range DetectPipelineIdentities() {
+		pipelines = append(pipelines, identity.String())
+	}
+	report("Pipelines: %s", listOrNone(pipelines))
+	report("Execution context: %s", config.Execution)
+
+	var enabled, disabled []string
+	for _, t := range Registry() {
+		if err := config.authorize(t); err != nil {
+			disabled = append(disabled, fmt.Sprintf("%s: %v", t.Name, err))
+			continue
+		}
+		enabled = append(enabled, t.Name)
+	}
+	report("Enabled techniques: %s", listOrNone(enabled))
+	for _, reason := range disabled {
+		report("Disabled %s", reason)
+	}
+
+	awsIdentity, awsDetail := selfCheckAws(ctx, config)
+	report("AWS caller identity: %s", awsDetail)
+	gcpPrincipal, gcpDetail := selfCheckGcp(ctx, config)
+	report("GCP principal: %s", gcpDetail)
+
+	sinks := config.selfCheckSinks(ctx)
+	var sinkList []interface{}
+	for _, sink := range sinks {
+		report("Sink %s %s: reachable=%t %s", sink.Name, sink.Target, sink.Reachable, sink.Detail)
+		sinkList = append(sinkList, map[string]interface{}{
+			"name":      sink.Name,
+			"target":    sink.Target,
+			"reachable": sink.Reachable,
+			"detail":    sink.Detail,
+		})
+	}
+
+	values := map[string]interface{}{
+		"version":                   config.Version,
+		"engagement_id":             config.engagementID(),
+		"engagement_time_remaining": remaining,
+		"armed":                     armed,
+		"arming_state":              state,
+		"pipelines":                 pipelines,
+		"enabled_techniques":        enabled,
+		"disabled_techniques":       disabled,
+		"aws_caller_identity":       awsIdentity,
+		"gcp_principal":             gcpPrincipal,
+		"sinks":                     sinkList,
+	}
+	for key, value := range values {
+		if err := d.Set(key, value); err != nil {
+			return diag.FromErr(fmt.Errorf("error setting %s: %v", key, err))
+		}
+	}
+
+	d.SetId("self-check")
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Self Check",
+		Detail:   strings.Join(lines, "\n"),
+	}}
+}
+
+// armingState explains whether techniques may run at now, and how long the
+// engagement window has left
+func (c *ProviderConfig) armingState(now time.Time) (bool, string, string) {
+	if c.Engagement == nil {
+		return true, "armed without an engagement config (development build)", ""
+	}
+
+	if err := c.Engagement.Active(now); err != nil {
+		return false, fmt.Sprintf("disarmed: %v", err), ""
+	}
+
+	remaining := c.Engagement.NotAfter.Sub(now).Round(time.Minute).String()
+	if !c.EngagementVerified {
+		return true, fmt.Sprintf("armed by engagement %s; signature not verified (development build)", c.Engagement.ID), remaining
+	}
+	return true, fmt.Sprintf("armed by engagement %s; signature verified", c.Engagement.ID), remaining
+}
+
+// selfCheckAws resolves the AWS caller and checks it against the engagement scope
+func selfCheckAws(ctx context.Context, config *ProviderConfig) (string, string) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String("us-east-1")})
+	if err != nil {
+		return "", fmt.Sprintf("unavailable: %v", err)
+	}
+
+	identity, err := config.callerIdentity(ctx, sess)
+	if err != nil {
+		return "", fmt.Sprintf("unavailable (%s)", classifyError(err).Class)
+	}
+
+	arn := aws.StringValue(identity.Arn)
+	if err := config.checkAwsScope(ctx, sess); err != nil {
+		return arn, fmt.Sprintf("%s (out of scope: %v)", arn, err)
+	}
+	return arn, fmt.Sprintf("%s (in scope)", arn)
+}
+
+// selfCheckGcp resolves the principal behind the application default
+// credentials and checks GOOGLE_CLOUD_PROJECT against the engagement scope
+func selfCheckGcp(ctx context.Context, config *ProviderConfig) (string, string) {
+	creds, err := google.FindDefaultCredentials(ctx)
+	if err != nil {
+		return "", "no application default credentials"
+	}
+
+	principal := ""
+	if len(creds.JSON) > 0 {
+		var key struct {
+			Type                           string `json:"type"`
+			ClientEmail                    string `json:"client_email"`
+			ClientID                       string `json:"client_id"`
+			ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+			Audience                       string `json:"audience"`
+		}
+		if err := json.Unmarshal(creds.JSON, &key); err == nil {
+			switch {
+			case key.ClientEmail != "":
+				principal = "serviceAccount:" + key.ClientEmail
+			case key.ServiceAccountImpersonationURL != "":
+				principal = "impersonated via " + key.ServiceAccountImpersonationURL
+			case key.Type == "external_account":
+				principal = "external account " + key.Audience
+			case key.Type == "authorized_user":
+				principal = "user credentials for client " + key.ClientID
+			}
+		}
+	} else if email, err := metadata.EmailWithContext(ctx, "default"); err == nil {
+		principal = "serviceAccount:" + email
+	}
+	if principal == "" {
+		return "", "credentials found but principal unknown"
+	}
+
+	project := creds.ProjectID
+	if env := os.Getenv("GOOGLE_CLOUD_PROJECT"); env != "" {
+		project = env
+	}
+	if project == "" {
+		return principal, fmt.Sprintf("%s (no project configured)", principal)
+	}
+	if err := config.checkGcpScope(project); err != nil {
+		return principal, fmt.Sprintf("%s (out of scope: %v)", principal, err)
+	}
+	return principal, fmt.Sprintf("%s (project %s in scope)", principal, project)
+}
+
+// selfCheckSinks lists every destination the provider writes to. File sinks
+// are checked for write permission without creating anything; webhooks found
+// in the root module are probed with HEAD so no data is sent.
+func (c *ProviderConfig) selfCheckSinks(ctx context.Context) []selfCheckSink {
+	var sinks []selfCheckSink
+
+	if c.Findings != nil && c.Findings.reportPath != "" {
+		sinks = append(sinks, checkDirSink("reports", c.Findings.reportPath))
+	}
+	if c.Rotation != nil {
+		sinks = append(sinks, checkDirSink("rotation_manifest", filepath.Dir(c.Rotation.path)))
+	}
+	if c.Journal != nil {
+		sinks = append(sinks, checkDirSink("journal", filepath.Dir(c.Journal.path)))
+	}
+
+	for _, url := range configuredWebhooks(".") {
+		sinks = append(sinks, c.checkWebhookSink(ctx, url))
+	}
+	return sinks
+}
+
+// checkDirSink checks that dir, or the nearest parent that exists when dir
+// has not been created yet, is a writable directory. Nothing is created.
+func checkDirSink(name, dir string) selfCheckSink {
+	sink := selfCheckSink{Name: name, Target: dir}
+
+	existing := filepath.Clean(dir)
+	info, err := os.Stat(existing)
+	for os.IsNotExist(err) && filepath.Dir(existing) != existing {
+		existing = filepath.Dir(existing)
+		info, err = os.Stat(existing)
+	}
+	switch {
+	case err != nil:
+		sink.Detail = err.Error()
+		return sink
+	case !info.IsDir():
+		sink.Detail = fmt.Sprintf("%s is not a directory", existing)
+		return sink
+	}
+	if err := dirWritable(existing, info); err != nil {
+		sink.Detail = fmt.Sprintf("%s is not writable: %v", existing, err)
+		return sink
+	}
+
+	sink.Reachable = true
+	sink.Detail = "writable"
+	if existing != filepath.Clean(dir) {
+		sink.Detail = fmt.Sprintf("writable; will be created under %s", existing)
+	}
+	return sink
+}
+
+func (c *ProviderConfig) checkWebhookSink(ctx context.Context, url string) selfCheckSink {
+	sink := selfCheckSink{Name: "webhook", Target: url}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		sink.Detail = err.Error()
+		return sink
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		sink.Detail = err.Error()
+		return sink
+	}
+	resp.Body.Close()
+
+	sink.Reachable = true
+	sink.Detail = resp.Status
+	return sink
+}
+
+// configuredWebhooks returns the distinct webhook_url values in the root module
+func configuredWebhooks(dir string) []string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+
+	seen := make(map[string]bool)
+	var urls []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, match := range webhookURLPattern.FindAllStringSubmatch(string(content), -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				urls = append(urls, match[1])
+			}
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
